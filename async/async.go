@@ -0,0 +1,67 @@
+//go:build !co
+
+// Code generated by github.com/goghcrow/go-co DO NOT EDIT.
+package async
+
+import (
+	"context"
+	"errors"
+	ʂɘʠ "github.com/goghcrow/go-co/seq"
+)
+
+// ErrPending is returned by Run if the loop finished before the task completed,
+// e.g., awaiting the task never completed
+var ErrPending = errors.New("async: task pending after the loop finished")
+
+// Go starts the async func f on the loop, the first step of f is scheduled instead of run immediately,
+// the task completes with the returned value, or the error by Fail,
+// or the *seq.PanicError if f panicked, Yield(nil) resumes f after the ready callbacks
+func Go[T any](l *Loop, ctx context.Context, f func(context.Context) ʂɘʠ.ResultIterator[Awaitable, T]) *Task[T] {
+	t := newTask[T](l, ctx)
+
+	var it ʂɘʠ.ResultIterator[Awaitable, T]
+	var step func()
+	resume := func() { l.enqueue(step) }
+	step = func() {
+		if t.done {
+			return
+		}
+		if it == nil {
+			it = ʂɘʠ.Catch[ʂɘʠ.ResultIterator[Awaitable, T], Awaitable](f(t.ctx))
+		}
+		if !it.MoveNext() {
+			if err := it.Err(); err != nil {
+				var zero T
+				t.complete(zero, err)
+				return
+			}
+			t.complete(it.Result(), nil)
+			return
+		}
+		switch a := it.Current().(type) {
+		case nil:
+			resume()
+		case failure:
+			_ = ʂɘʠ.Close[Awaitable](it)
+			var zero T
+			t.complete(zero, a.err)
+		default:
+			a.OnCompleted(resume)
+		}
+	}
+
+	l.enqueue(step)
+	return t
+}
+
+// Run runs the async func f on a new loop until the loop finished
+func Run[T any](ctx context.Context, f func(context.Context) ʂɘʠ.ResultIterator[Awaitable, T], opts ...Option) (T, error) {
+	l := NewLoop(opts...)
+	t := Go(l, ctx, f)
+	l.Run()
+	if !t.done {
+		var zero T
+		return zero, ErrPending
+	}
+	return t.Result()
+}
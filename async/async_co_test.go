@@ -0,0 +1,279 @@
+//go:build co
+
+package async
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	. "github.com/goghcrow/go-co"
+	"github.com/goghcrow/go-co/seq"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestRun(t *testing.T) {
+	l := NewLoop(WithVirtualTime(epoch))
+	var log []time.Duration
+
+	task := Go(l, context.Background(), func(ctx context.Context) Generator[Awaitable, string] {
+		for i := 1; i <= 3; i++ {
+			Yield(Await(Sleep(l, ctx, time.Duration(i)*time.Second)))
+			log = append(log, l.Now().Sub(epoch))
+		}
+		return Return[Awaitable]("done")
+	})
+	assertEqual(t, task.Done(), false)
+	l.Run()
+
+	v, err := task.Result()
+	assertEqual(t, v, "done")
+	assertEqual(t, err, nil)
+	assertEqual(t, log, []time.Duration{time.Second, 3 * time.Second, 6 * time.Second})
+}
+
+func TestAwaitTask(t *testing.T) {
+	l := NewLoop(WithVirtualTime(epoch))
+	double := func(x int) func(context.Context) Generator[Awaitable, int] {
+		return func(ctx context.Context) Generator[Awaitable, int] {
+			Yield(Await(Sleep(l, ctx, time.Second)))
+			return Return[Awaitable](x * 2)
+		}
+	}
+
+	task := Go(l, context.Background(), func(ctx context.Context) Generator[Awaitable, int] {
+		t1 := Go(l, ctx, double(1))
+		Yield(Await(t1))
+		x, _ := t1.Result()
+
+		t2 := Go(l, ctx, double(x))
+		Yield(Await(t2))
+		y, _ := t2.Result()
+		return Return[Awaitable](y)
+	})
+	l.Run()
+
+	v, err := task.Result()
+	assertEqual(t, v, 4)
+	assertEqual(t, err, nil)
+	assertEqual(t, l.Now().Sub(epoch), 2*time.Second)
+}
+
+func TestWhenAll(t *testing.T) {
+	l := NewLoop(WithVirtualTime(epoch))
+	after := func(d time.Duration, v int) *Task[int] {
+		return Go(l, context.Background(), func(ctx context.Context) Generator[Awaitable, int] {
+			Yield(Await(Sleep(l, ctx, d)))
+			return Return[Awaitable](v)
+		})
+	}
+
+	all := WhenAll(l, after(3*time.Second, 1), after(time.Second, 2), after(2*time.Second, 3))
+	l.Run()
+
+	xs, err := all.Result()
+	assertEqual(t, xs, []int{1, 2, 3})
+	assertEqual(t, err, nil)
+	// run concurrently
+	assertEqual(t, l.Now().Sub(epoch), 3*time.Second)
+
+	// the first error in order of completion
+	errA, errB := errors.New("a"), errors.New("b")
+	fail := func(d time.Duration, err error) *Task[int] {
+		return Go(l, context.Background(), func(ctx context.Context) Generator[Awaitable, int] {
+			Yield(Await(Sleep(l, ctx, d)))
+			Yield(Fail(err))
+			return Return[Awaitable](0)
+		})
+	}
+	all = WhenAll(l, fail(2*time.Second, errA), after(3*time.Second, 1), fail(time.Second, errB))
+	l.Run()
+	xs, err = all.Result()
+	assertEqual(t, xs == nil, true)
+	assertEqual(t, err, errB)
+
+	all = WhenAll[int](l)
+	xs, err = all.Result()
+	assertEqual(t, all.Done(), true)
+	assertEqual(t, xs, []int{})
+	assertEqual(t, err, nil)
+}
+
+func TestWhenAny(t *testing.T) {
+	l := NewLoop(WithVirtualTime(epoch))
+	ctx := context.Background()
+
+	var done []int
+	task := Go(l, ctx, func(ctx context.Context) Generator[Awaitable, int] {
+		ts := []*Task[struct{}]{
+			Sleep(l, ctx, 3*time.Second),
+			Sleep(l, ctx, time.Second),
+			Sleep(l, ctx, 2*time.Second),
+		}
+		for len(ts) > 0 {
+			first := WhenAny(l, ts...)
+			Yield(Await(first))
+			i, _ := first.Result()
+			done = append(done, int(l.Now().Sub(epoch)/time.Second))
+			ts = append(ts[:i], ts[i+1:]...)
+		}
+		return Return[Awaitable](len(done))
+	})
+	l.Run()
+
+	v, _ := task.Result()
+	assertEqual(t, v, 3)
+	assertEqual(t, done, []int{1, 2, 3})
+
+	_, err := WhenAny[int](l).Result()
+	assertEqual(t, err, errNoTask)
+}
+
+func TestCancel(t *testing.T) {
+	l := NewLoop(WithVirtualTime(epoch))
+	var log []string
+
+	task := Go(l, context.Background(), func(ctx context.Context) Generator[Awaitable, int] {
+		defer func() { log = append(log, "defer") }()
+		s := Sleep(l, ctx, time.Hour)
+		Yield(Await(s))
+		if _, err := s.Result(); err != nil {
+			log = append(log, "cancelled")
+			Yield(Fail(err))
+		}
+		log = append(log, "unreachable")
+		return Return[Awaitable](1)
+	})
+	Go(l, context.Background(), func(ctx context.Context) Generator[Awaitable, int] {
+		Yield(Await(Sleep(l, ctx, time.Second)))
+		task.Cancel()
+		return Return[Awaitable](0)
+	})
+	l.Run()
+
+	_, err := task.Result()
+	assertEqual(t, err, context.Canceled)
+	assertEqual(t, log, []string{"cancelled", "defer"})
+	// the cancelled timer is not waited
+	assertEqual(t, l.Now().Sub(epoch), time.Second)
+	assertEqual(t, task.Context().Err(), context.Canceled)
+}
+
+func TestCancelNested(t *testing.T) {
+	l := NewLoop(WithVirtualTime(epoch))
+
+	// the cancellation is propagated to the nested tasks
+	var inner *Task[int]
+	outer := Go(l, context.Background(), func(ctx context.Context) Generator[Awaitable, int] {
+		inner = Go(l, ctx, func(ctx context.Context) Generator[Awaitable, int] {
+			s := Sleep(l, ctx, time.Hour)
+			Yield(Await(s))
+			if _, err := s.Result(); err != nil {
+				Yield(Fail(err))
+			}
+			return Return[Awaitable](1)
+		})
+		Yield(Await(inner))
+		return Return[Awaitable](2)
+	})
+	l.enqueue(outer.Cancel)
+	l.Run()
+
+	_, err := inner.Result()
+	assertEqual(t, err, context.Canceled)
+	// the outer ignores the error of the inner
+	v, err := outer.Result()
+	assertEqual(t, v, 2)
+	assertEqual(t, err, nil)
+	assertEqual(t, l.Now(), epoch)
+}
+
+func TestCancelByContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	v, err := Run(ctx, func(ctx context.Context) Generator[Awaitable, int] {
+		s := Sleep(LoopOf(ctx), ctx, time.Hour)
+		Yield(Await(s))
+		_, err := s.Result()
+		if err != nil {
+			Yield(Fail(err))
+		}
+		return Return[Awaitable](1)
+	}, WithVirtualTime(epoch))
+	assertEqual(t, v, 0)
+	assertEqual(t, err, context.Canceled)
+}
+
+func TestFromCallback(t *testing.T) {
+	l := NewLoop()
+	echo := func(v string) *Task[string] {
+		return FromCallback(l, context.Background(), func(done func(string, error)) {
+			go func() {
+				time.Sleep(time.Millisecond)
+				done(v, nil)
+			}()
+		})
+	}
+
+	task := Go(l, context.Background(), func(ctx context.Context) Generator[Awaitable, string] {
+		a, b := echo("a"), echo("b")
+		Yield(Await(WhenAll(l, a, b)))
+		x, _ := a.Result()
+		y, _ := b.Result()
+		return Return[Awaitable](x + y)
+	})
+	l.Run()
+
+	v, err := task.Result()
+	assertEqual(t, v, "ab")
+	assertEqual(t, err, nil)
+}
+
+func TestYieldNil(t *testing.T) {
+	l := NewLoop()
+	var log []string
+	worker := func(name string) func(context.Context) Generator[Awaitable, int] {
+		return func(ctx context.Context) Generator[Awaitable, int] {
+			for i := 0; i < 2; i++ {
+				log = append(log, name)
+				Yield[Awaitable](nil)
+			}
+			return Return[Awaitable](0)
+		}
+	}
+	Go(l, context.Background(), worker("a"))
+	Go(l, context.Background(), worker("b"))
+	l.Run()
+	// interleaved
+	assertEqual(t, log, []string{"a", "b", "a", "b"})
+}
+
+func TestPanic(t *testing.T) {
+	_, err := Run(context.Background(), func(ctx context.Context) Generator[Awaitable, int] {
+		Yield[Awaitable](nil)
+		panic("boom")
+	})
+	var pe *seq.PanicError
+	assertEqual(t, errors.As(err, &pe), true)
+	assertEqual(t, pe.Value, "boom")
+}
+
+func TestPending(t *testing.T) {
+	never := &Task[int]{}
+	_, err := Run(context.Background(), func(ctx context.Context) Generator[Awaitable, int] {
+		Yield(Await(never))
+		return Return[Awaitable](1)
+	})
+	assertEqual(t, err, ErrPending)
+}
+
+func assertEqual(t *testing.T, got, expect any) {
+	t.Helper()
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expect\n%v\n\ngot\n%v", expect, got)
+	}
+}
@@ -0,0 +1,494 @@
+//go:build !co
+
+// Code generated by github.com/goghcrow/go-co DO NOT EDIT.
+package async
+
+import (
+	"context"
+	"errors"
+	"github.com/goghcrow/go-co/seq"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestRun(t *testing.T) {
+	l := NewLoop(WithVirtualTime(epoch))
+	var log []time.Duration
+
+	task := Go(l, context.Background(), // func(ctx context.Context) Generator[Awaitable, string] {
+		// 	for i := 1; i <= 3; i++ {
+		// 		Yield(Await(Sleep(l, ctx, time.Duration(i)*time.Second)))
+		// 		log = append(log, l.Now().Sub(epoch))
+		// 	}
+		// 	return Return[Awaitable]("done")
+		// }
+		func(ctx context.Context) seq.ResultIterator[Awaitable, string] {
+			return seq.StartResult[Awaitable, string](
+				seq.Combine[Awaitable](
+					seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+						i := 1
+						return seq.For[Awaitable](func() bool {
+							return i <= 3
+						}, func() {
+							i++
+						}, seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+							return seq.Bind[Awaitable](Await(Sleep(l, ctx, time.Duration(i)*time.Second)), func() seq.Seq[Awaitable] {
+
+								log = append(log, l.Now().Sub(epoch))
+								return seq.Normal[Awaitable]()
+							})
+						}))
+					}),
+					seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+						return seq.ReturnValue[Awaitable, string]("done")
+					})),
+			)
+		})
+	assertEqual(t, task.Done(), false)
+	l.Run()
+
+	v, err := task.Result()
+	assertEqual(t, v, "done")
+	assertEqual(t, err, nil)
+	assertEqual(t, log, []time.Duration{time.Second, 3 * time.Second, 6 * time.Second})
+}
+
+func TestAwaitTask(t *testing.T) {
+	l := NewLoop(WithVirtualTime(epoch))
+	double := func(x int) func(context.Context) seq.ResultIterator[Awaitable, int] {
+		return func // func(ctx context.Context) Generator[Awaitable, int] {
+		// 	Yield(Await(Sleep(l, ctx, time.Second)))
+		// 	return Return[Awaitable](x * 2)
+		// }
+		(ctx context.Context) seq.ResultIterator[Awaitable, int] {
+			return seq.StartResult[Awaitable, int](seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+				return seq.Bind[Awaitable](Await(Sleep(l, ctx, time.Second)), func() seq.Seq[Awaitable] {
+					return seq.ReturnValue[Awaitable, int](x * 2)
+				})
+			}))
+		}
+	}
+
+	task := Go(l, context.Background(), // func(ctx context.Context) Generator[Awaitable, int] {
+		// 	t1 := Go(l, ctx, double(1))
+		// 	Yield(Await(t1))
+		// 	x, _ := t1.Result()
+		//
+		// 	t2 := Go(l, ctx, double(x))
+		// 	Yield(Await(t2))
+		// 	y, _ := t2.Result()
+		// 	return Return[Awaitable](y)
+		// }
+		func(ctx context.Context) seq.ResultIterator[Awaitable, int] {
+			return seq.StartResult[Awaitable, int](seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+				t1 := Go(l, ctx, double(1))
+				return seq.Bind[Awaitable](Await(t1), func() seq.Seq[Awaitable] {
+
+					x, _ := t1.Result()
+
+					t2 := Go(l, ctx, double(x))
+					return seq.Bind[Awaitable](Await(t2), func() seq.Seq[Awaitable] {
+
+						y, _ := t2.Result()
+						return seq.ReturnValue[Awaitable, int](y)
+					})
+				})
+			}))
+		})
+	l.Run()
+
+	v, err := task.Result()
+	assertEqual(t, v, 4)
+	assertEqual(t, err, nil)
+	assertEqual(t, l.Now().Sub(epoch), 2*time.Second)
+}
+
+func TestWhenAll(t *testing.T) {
+	l := NewLoop(WithVirtualTime(epoch))
+	after := func(d time.Duration, v int) *Task[int] {
+		return Go(l, context.Background(), // func(ctx context.Context) Generator[Awaitable, int] {
+			// 	Yield(Await(Sleep(l, ctx, d)))
+			// 	return Return[Awaitable](v)
+			// }
+			func(ctx context.Context) seq.ResultIterator[Awaitable, int] {
+				return seq.StartResult[Awaitable, int](seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+					return seq.Bind[Awaitable](Await(Sleep(l, ctx, d)), func() seq.Seq[Awaitable] {
+						return seq.ReturnValue[Awaitable, int](v)
+					})
+				}))
+			})
+	}
+
+	all := WhenAll(l, after(3*time.Second, 1), after(time.Second, 2), after(2*time.Second, 3))
+	l.Run()
+
+	xs, err := all.Result()
+	assertEqual(t, xs, []int{1, 2, 3})
+	assertEqual(t, err, nil)
+
+	assertEqual(t, l.Now().Sub(epoch), 3*time.Second)
+
+	errA, errB := errors.New("a"), errors.New("b")
+	fail := func(d time.Duration, err error) *Task[int] {
+		return Go(l, context.Background(), // func(ctx context.Context) Generator[Awaitable, int] {
+			// 	Yield(Await(Sleep(l, ctx, d)))
+			// 	Yield(Fail(err))
+			// 	return Return[Awaitable](0)
+			// }
+			func(ctx context.Context) seq.ResultIterator[Awaitable, int] {
+				return seq.StartResult[Awaitable, int](seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+					return seq.Bind[Awaitable](Await(Sleep(l, ctx, d)), func() seq.Seq[Awaitable] {
+						return seq.Bind[Awaitable](Fail(err), func() seq.Seq[Awaitable] {
+							return seq.ReturnValue[Awaitable, int](0)
+						})
+					})
+				}))
+			})
+	}
+	all = WhenAll(l, fail(2*time.Second, errA), after(3*time.Second, 1), fail(time.Second, errB))
+	l.Run()
+	xs, err = all.Result()
+	assertEqual(t, xs == nil, true)
+	assertEqual(t, err, errB)
+
+	all = WhenAll[int](l)
+	xs, err = all.Result()
+	assertEqual(t, all.Done(), true)
+	assertEqual(t, xs, []int{})
+	assertEqual(t, err, nil)
+}
+
+func TestWhenAny(t *testing.T) {
+	l := NewLoop(WithVirtualTime(epoch))
+	ctx := context.Background()
+
+	var done []int
+	task := Go(l, ctx, // func(ctx context.Context) Generator[Awaitable, int] {
+		// 	ts := []*Task[struct{}]{
+		// 		Sleep(l, ctx, 3*time.Second),
+		// 		Sleep(l, ctx, time.Second),
+		// 		Sleep(l, ctx, 2*time.Second),
+		// 	}
+		// 	for len(ts) > 0 {
+		// 		first := WhenAny(l, ts...)
+		// 		Yield(Await(first))
+		// 		i, _ := first.Result()
+		// 		done = append(done, int(l.Now().Sub(epoch)/time.Second))
+		// 		ts = append(ts[:i], ts[i+1:]...)
+		// 	}
+		// 	return Return[Awaitable](len(done))
+		// }
+		func(ctx context.Context) seq.ResultIterator[Awaitable, int] {
+			return seq.StartResult[Awaitable, int](seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+				ts := []*Task[struct{}]{
+					Sleep(l, ctx, 3*time.Second),
+					Sleep(l, ctx, time.Second),
+					Sleep(l, ctx, 2*time.Second),
+				}
+				return seq.Combine[Awaitable](
+					seq.While[Awaitable](func() bool {
+						return len(ts) > 0
+					}, seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+
+						first := WhenAny(l, ts...)
+						return seq.Bind[Awaitable](Await(first), func() seq.Seq[Awaitable] {
+
+							i, _ := first.Result()
+							done = append(done, int(l.Now().Sub(epoch)/time.Second))
+							ts = append(ts[:i], ts[i+1:]...)
+							return seq.Normal[Awaitable]()
+						})
+					})),
+					seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+						return seq.ReturnValue[Awaitable, int](len(done))
+					}))
+			}))
+		})
+	l.Run()
+
+	v, _ := task.Result()
+	assertEqual(t, v, 3)
+	assertEqual(t, done, []int{1, 2, 3})
+
+	_, err := WhenAny[int](l).Result()
+	assertEqual(t, err, errNoTask)
+}
+
+func TestCancel(t *testing.T) {
+	l := NewLoop(WithVirtualTime(epoch))
+	var log []string
+
+	task := Go(l, context.Background(), // func(ctx context.Context) Generator[Awaitable, int] {
+		// 	defer func() { log = append(log, "defer") }()
+		// 	s := Sleep(l, ctx, time.Hour)
+		// 	Yield(Await(s))
+		// 	if _, err := s.Result(); err != nil {
+		// 		log = append(log, "cancelled")
+		// 		Yield(Fail(err))
+		// 	}
+		// 	log = append(log, "unreachable")
+		// 	return Return[Awaitable](1)
+		// }
+		func(ctx context.Context) seq.ResultIterator[Awaitable, int] {
+			return seq.StartResult[Awaitable, int](
+				seq.Defer[Awaitable](func() { log = append(log, "defer") }, func() seq.Seq[Awaitable] {
+
+					s := Sleep(l, ctx, time.Hour)
+					return seq.Bind[Awaitable](Await(s), func() seq.Seq[Awaitable] {
+						return seq.Combine[Awaitable](seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+							if _, err := s.Result(); err != nil {
+								log = append(log, "cancelled")
+								return seq.Bind[Awaitable](Fail(err),
+									seq.Normal[Awaitable],
+								)
+							}
+							return seq.Normal[Awaitable]()
+						}), seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+
+							log = append(log, "unreachable")
+							return seq.ReturnValue[Awaitable, int](1)
+						}))
+					})
+				}),
+			)
+		})
+	Go(l, context.Background(), // func(ctx context.Context) Generator[Awaitable, int] {
+		// 	Yield(Await(Sleep(l, ctx, time.Second)))
+		// 	task.Cancel()
+		// 	return Return[Awaitable](0)
+		// }
+		func(ctx context.Context) seq.ResultIterator[Awaitable, int] {
+			return seq.StartResult[Awaitable, int](seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+				return seq.Bind[Awaitable](Await(Sleep(l, ctx, time.Second)), func() seq.Seq[Awaitable] {
+
+					task.Cancel()
+					return seq.ReturnValue[Awaitable, int](0)
+				})
+			}))
+		})
+	l.Run()
+
+	_, err := task.Result()
+	assertEqual(t, err, context.Canceled)
+	assertEqual(t, log, []string{"cancelled", "defer"})
+
+	assertEqual(t, l.Now().Sub(epoch), time.Second)
+	assertEqual(t, task.Context().Err(), context.Canceled)
+}
+
+func TestCancelNested(t *testing.T) {
+	l := NewLoop(WithVirtualTime(epoch))
+
+	var inner *Task[int]
+	outer := Go(l, context.Background(), func(ctx context.Context) seq.ResultIterator[Awaitable, int] {
+		return seq.StartResult[Awaitable, int](seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+			inner = Go(l, ctx, // func(ctx context.Context) Generator[Awaitable, int] {
+				// 	s := Sleep(l, ctx, time.Hour)
+				// 	Yield(Await(s))
+				// 	if _, err := s.Result(); err != nil {
+				// 		Yield(Fail(err))
+				// 	}
+				// 	return Return[Awaitable](1)
+				// }
+				// func(ctx context.Context) Generator[Awaitable, int] {
+				// 	inner = Go(l, ctx, func(ctx context.Context) Generator[Awaitable, int] {
+				// 		s := Sleep(l, ctx, time.Hour)
+				// 		Yield(Await(s))
+				// 		if _, err := s.Result(); err != nil {
+				// 			Yield(Fail(err))
+				// 		}
+				// 		return Return[Awaitable](1)
+				// 	})
+				// 	Yield(Await(inner))
+				// 	return Return[Awaitable](2)
+				// }
+				func(ctx context.Context) seq.ResultIterator[Awaitable, int] {
+					return seq.StartResult[Awaitable, int](seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+						s := Sleep(l, ctx, time.Hour)
+						return seq.Bind[Awaitable](Await(s), func() seq.Seq[Awaitable] {
+							return seq.Combine[Awaitable](seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+								if _, err := s.Result(); err != nil {
+									return seq.Bind[Awaitable](Fail(err),
+										seq.Normal[Awaitable],
+									)
+								}
+								return seq.Normal[Awaitable]()
+							}), seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+								return seq.ReturnValue[Awaitable, int](1)
+							}))
+						})
+					}))
+				})
+			return seq.Bind[Awaitable](Await(inner), func() seq.Seq[Awaitable] {
+				return seq.ReturnValue[Awaitable, int](2)
+			})
+		}))
+	})
+	l.enqueue(outer.Cancel)
+	l.Run()
+
+	_, err := inner.Result()
+	assertEqual(t, err, context.Canceled)
+
+	v, err := outer.Result()
+	assertEqual(t, v, 2)
+	assertEqual(t, err, nil)
+	assertEqual(t, l.Now(), epoch)
+}
+
+func TestCancelByContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	v, err := Run(ctx, // func(ctx context.Context) Generator[Awaitable, int] {
+		// 	s := Sleep(LoopOf(ctx), ctx, time.Hour)
+		// 	Yield(Await(s))
+		// 	_, err := s.Result()
+		// 	if err != nil {
+		// 		Yield(Fail(err))
+		// 	}
+		// 	return Return[Awaitable](1)
+		// }
+		func(ctx context.Context) seq.ResultIterator[Awaitable, int] {
+			return seq.StartResult[Awaitable, int](seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+				s := Sleep(LoopOf(ctx), ctx, time.Hour)
+				return seq.Bind[Awaitable](Await(s), func() seq.Seq[Awaitable] {
+
+					_, err := s.Result()
+					return seq.Combine[Awaitable](seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+						if err != nil {
+							return seq.Bind[Awaitable](Fail(err),
+								seq.Normal[Awaitable],
+							)
+						}
+						return seq.Normal[Awaitable]()
+					}), seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+						return seq.ReturnValue[Awaitable, int](1)
+					}))
+				})
+			}))
+		}, WithVirtualTime(epoch))
+	assertEqual(t, v, 0)
+	assertEqual(t, err, context.Canceled)
+}
+
+func TestFromCallback(t *testing.T) {
+	l := NewLoop()
+	echo := func(v string) *Task[string] {
+		return FromCallback(l, context.Background(), func(done func(string, error)) {
+			go func() {
+				time.Sleep(time.Millisecond)
+				done(v, nil)
+			}()
+		})
+	}
+
+	task := Go(l, context.Background(), // func(ctx context.Context) Generator[Awaitable, string] {
+		// 	a, b := echo("a"), echo("b")
+		// 	Yield(Await(WhenAll(l, a, b)))
+		// 	x, _ := a.Result()
+		// 	y, _ := b.Result()
+		// 	return Return[Awaitable](x + y)
+		// }
+		func(ctx context.Context) seq.ResultIterator[Awaitable, string] {
+			return seq.StartResult[Awaitable, string](seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+				a, b := echo("a"), echo("b")
+				return seq.Bind[Awaitable](Await(WhenAll(l, a, b)), func() seq.Seq[Awaitable] {
+
+					x, _ := a.Result()
+					y, _ := b.Result()
+					return seq.ReturnValue[Awaitable, string](x + y)
+				})
+			}))
+		})
+	l.Run()
+
+	v, err := task.Result()
+	assertEqual(t, v, "ab")
+	assertEqual(t, err, nil)
+}
+
+func TestYieldNil(t *testing.T) {
+	l := NewLoop()
+	var log []string
+	worker := func(name string) func(context.Context) seq.ResultIterator[Awaitable, int] {
+		return func // func(ctx context.Context) Generator[Awaitable, int] {
+		// 	for i := 0; i < 2; i++ {
+		// 		log = append(log, name)
+		// 		Yield[Awaitable](nil)
+		// 	}
+		// 	return Return[Awaitable](0)
+		// }
+		(ctx context.Context) seq.ResultIterator[Awaitable, int] {
+			return seq.StartResult[Awaitable, int](
+				seq.Combine[Awaitable](
+					seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+						i := 0
+						return seq.For[Awaitable](func() bool {
+							return i < 2
+						}, func() {
+							i++
+						}, seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+
+							log = append(log, name)
+							return seq.Bind[Awaitable](nil,
+								seq.Normal[Awaitable],
+							)
+						}))
+					}),
+					seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+						return seq.ReturnValue[Awaitable, int](0)
+					})),
+			)
+		}
+	}
+	Go(l, context.Background(), worker("a"))
+	Go(l, context.Background(), worker("b"))
+	l.Run()
+
+	assertEqual(t, log, []string{"a", "b", "a", "b"})
+}
+
+func TestPanic(t *testing.T) {
+	_, err := Run(context.Background(), // func(ctx context.Context) Generator[Awaitable, int] {
+		// 	Yield[Awaitable](nil)
+		// 	panic("boom")
+		// }
+		func(ctx context.Context) seq.ResultIterator[Awaitable, int] {
+			return seq.StartResult[Awaitable, int](seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+				return seq.Bind[Awaitable](nil, func() seq.Seq[Awaitable] {
+
+					panic("boom")
+				})
+			}))
+		})
+	var pe *seq.PanicError
+	assertEqual(t, errors.As(err, &pe), true)
+	assertEqual(t, pe.Value, "boom")
+}
+
+func TestPending(t *testing.T) {
+	never := &Task[int]{}
+	_, err := Run(context.Background(), // func(ctx context.Context) Generator[Awaitable, int] {
+		// 	Yield(Await(never))
+		// 	return Return[Awaitable](1)
+		// }
+		func(ctx context.Context) seq.ResultIterator[Awaitable, int] {
+			return seq.StartResult[Awaitable, int](seq.Delay[Awaitable](func() seq.Seq[Awaitable] {
+				return seq.Bind[Awaitable](Await(never), func() seq.Seq[Awaitable] {
+					return seq.ReturnValue[Awaitable, int](1)
+				})
+			}))
+		})
+	assertEqual(t, err, ErrPending)
+}
+
+func assertEqual(t *testing.T, got, expect any) {
+	t.Helper()
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expect\n%v\n\ngot\n%v", expect, got)
+	}
+}
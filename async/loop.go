@@ -0,0 +1,208 @@
+// Package async is the goroutine-free async/await built on the generators,
+// the async func is a yield func yielding Awaitable, driven by a single-threaded Loop,
+// it is suspended at Yield(Await(task)), and resumed on the loop after the task completed
+//
+//	func fetch(ctx context.Context) Generator[async.Awaitable, string] {
+//		t := async.Sleep(async.LoopOf(ctx), ctx, time.Second)
+//		Yield(async.Await(t))
+//		if _, err := t.Result(); err != nil {
+//			Yield(async.Fail(err))
+//		}
+//		return "done"
+//	}
+//
+//	v, err := async.Run(ctx, fetch)
+package async
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// Loop is the single-threaded event loop, all the async funcs and callbacks are run
+// on the goroutine calling Run, the methods are not goroutine-safe except Post
+type Loop struct {
+	ready   []func()
+	timers  timers
+	active  int // the timers not stopped
+	refs    int // the pending callbacks posted from the other goroutines
+	virtual bool
+	now     time.Time // the virtual time
+
+	mu     sync.Mutex
+	posted []func()
+	wake   chan struct{}
+}
+
+type Option func(*Loop)
+
+// WithVirtualTime makes the timers fire without waiting, the clock jumps to the next timer
+// when the loop is idle, for deterministic testing
+func WithVirtualTime(start time.Time) Option {
+	return func(l *Loop) {
+		l.virtual = true
+		l.now = start
+	}
+}
+
+func NewLoop(opts ...Option) *Loop {
+	l := &Loop{wake: make(chan struct{}, 1)}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Now returns the current time of the loop, which is virtual if WithVirtualTime
+func (l *Loop) Now() time.Time {
+	if l.virtual {
+		return l.now
+	}
+	return time.Now()
+}
+
+// Post schedules f to run on the loop, which can be called from any goroutine
+func (l *Loop) Post(f func()) {
+	l.mu.Lock()
+	l.posted = append(l.posted, f)
+	l.mu.Unlock()
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run runs the loop until there is nothing to do,
+// i.e., no ready callbacks, no active timers and no pending callbacks
+func (l *Loop) Run() {
+	for {
+		l.drain()
+		if len(l.ready) > 0 {
+			f := l.ready[0]
+			l.ready[0] = nil
+			l.ready = l.ready[1:]
+			f()
+			continue
+		}
+		if next := l.nextTimer(); next != nil {
+			if l.virtual {
+				l.now = next.when
+			} else if d := time.Until(next.when); d > 0 && l.wait(d) {
+				continue // woken by Post
+			}
+			l.fireTimers()
+			continue
+		}
+		if l.refs > 0 {
+			l.wait(-1)
+			continue
+		}
+		return
+	}
+}
+
+func (l *Loop) enqueue(f func()) {
+	l.ready = append(l.ready, f)
+}
+
+// hold keeps the loop running until the callback of the other goroutine is posted
+func (l *Loop) hold() { l.refs++ }
+
+// release is called on the loop by the posted callback
+func (l *Loop) release() { l.refs-- }
+
+func (l *Loop) drain() {
+	l.mu.Lock()
+	posted := l.posted
+	l.posted = nil
+	l.mu.Unlock()
+	l.ready = append(l.ready, posted...)
+}
+
+// wait blocks until posted or timeout, d < 0 means no timeout
+func (l *Loop) wait(d time.Duration) (woken bool) {
+	if d < 0 {
+		<-l.wake
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-l.wake:
+		return true
+	case <-t.C:
+		return false
+	}
+}
+
+// ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓ Timer ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓
+
+type timer struct {
+	when    time.Time
+	seq     int // the timers of the same time fire in order of creation
+	f       func()
+	stopped bool
+}
+
+type timers struct {
+	xs  []*timer
+	seq int
+}
+
+func (h *timers) Len() int { return len(h.xs) }
+func (h *timers) Less(i, j int) bool {
+	if h.xs[i].when.Equal(h.xs[j].when) {
+		return h.xs[i].seq < h.xs[j].seq
+	}
+	return h.xs[i].when.Before(h.xs[j].when)
+}
+func (h *timers) Swap(i, j int) { h.xs[i], h.xs[j] = h.xs[j], h.xs[i] }
+func (h *timers) Push(x any)    { h.xs = append(h.xs, x.(*timer)) }
+func (h *timers) Pop() any {
+	n := len(h.xs)
+	x := h.xs[n-1]
+	h.xs[n-1] = nil
+	h.xs = h.xs[:n-1]
+	return x
+}
+
+// after schedules f to run on the loop after d
+func (l *Loop) after(d time.Duration, f func()) *timer {
+	l.timers.seq++
+	t := &timer{when: l.Now().Add(d), seq: l.timers.seq, f: f}
+	heap.Push(&l.timers, t)
+	l.active++
+	return t
+}
+
+func (l *Loop) stopTimer(t *timer) {
+	if !t.stopped {
+		t.stopped = true
+		l.active--
+	}
+}
+
+// the earliest active timer, the stopped are dropped lazily
+func (l *Loop) nextTimer() *timer {
+	for l.timers.Len() > 0 {
+		if t := l.timers.xs[0]; !t.stopped {
+			return t
+		}
+		heap.Pop(&l.timers)
+	}
+	return nil
+}
+
+func (l *Loop) fireTimers() {
+	now := l.Now()
+	for {
+		t := l.nextTimer()
+		if t == nil || t.when.After(now) {
+			return
+		}
+		heap.Pop(&l.timers)
+		l.stopTimer(t)
+		l.enqueue(t.f)
+	}
+}
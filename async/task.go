@@ -0,0 +1,234 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Awaitable is yielded by the async func to suspend until it completes,
+// OnCompleted is called on the loop, and k must be called on the loop only once
+type Awaitable interface {
+	OnCompleted(k func())
+}
+
+// Task is the eventual result of the async operation, which completes only once
+type Task[T any] struct {
+	task
+	val T
+	err error
+}
+
+// task is the non-generic part of Task, for the cancellation tree
+type task struct {
+	loop     *Loop
+	ctx      context.Context
+	cancel   context.CancelFunc
+	parent   *task
+	children map[*task]struct{}
+	onCancel func()
+	done     bool
+	ks       []func()
+}
+
+type taskKey struct{}
+
+// the ctx of the task is derived from parent, and cancelled after completed,
+// the task is cancelled synchronously with the parent task on the same loop,
+// otherwise, the cancellation of parent is watched by a goroutine and posted to the loop
+func newTask[T any](l *Loop, parent context.Context) *Task[T] {
+	t := &Task[T]{}
+	t.loop = l
+	t.ctx, t.cancel = context.WithCancel(context.WithValue(parent, taskKey{}, &t.task))
+
+	p, _ := parent.Value(taskKey{}).(*task)
+	switch {
+	case parent.Err() != nil:
+		l.enqueue(t.Cancel)
+	case p != nil && p.loop == l && p.ctx.Done() == parent.Done():
+		t.parent = p
+		if p.children == nil {
+			p.children = map[*task]struct{}{}
+		}
+		p.children[&t.task] = struct{}{}
+	case parent.Done() != nil:
+		l.hold()
+		go func() {
+			select {
+			case <-parent.Done():
+				l.Post(func() {
+					l.release()
+					t.Cancel()
+				})
+			case <-t.ctx.Done():
+				l.Post(l.release)
+			}
+		}()
+	}
+	return t
+}
+
+// LoopOf returns the loop of the task which ctx is derived from, or nil
+func LoopOf(ctx context.Context) *Loop {
+	if t, ok := ctx.Value(taskKey{}).(*task); ok {
+		return t.loop
+	}
+	return nil
+}
+
+// Context is cancelled if the task is cancelled or completed
+func (t *task) Context() context.Context { return t.ctx }
+
+// Done reports whether the task is completed
+func (t *task) Done() bool { return t.done }
+
+// Cancel cancels the context of the task and its children tasks,
+// the leaf task, e.g., Sleep, completes with context.Canceled immediately,
+// the async func is cancelled cooperatively, i.e., it is resumed by the cancelled children,
+// and decides how to finish, Cancel must be called on the loop
+func (t *task) Cancel() {
+	if t.done {
+		return
+	}
+	t.cancel()
+	for c := range t.children {
+		c.Cancel()
+	}
+	if t.onCancel != nil {
+		t.onCancel()
+	}
+}
+
+// OnCompleted implements Awaitable, k is called immediately if completed
+func (t *task) OnCompleted(k func()) {
+	if t.done {
+		k()
+		return
+	}
+	t.ks = append(t.ks, k)
+}
+
+// Result returns the result of the completed task, or the zero value if pending
+func (t *Task[T]) Result() (T, error) {
+	return t.val, t.err
+}
+
+func (t *Task[T]) complete(v T, err error) {
+	if t.done {
+		return
+	}
+	t.val, t.err, t.done = v, err, true
+	t.cancel()
+	if t.parent != nil {
+		delete(t.parent.children, &t.task)
+	}
+	ks := t.ks
+	t.ks = nil
+	for _, k := range ks {
+		k()
+	}
+}
+
+// Await suspends the async func until t completed by Yield(Await(t)),
+// the result is available by t.Result() after resumed
+func Await[T any](t *Task[T]) Awaitable {
+	return t
+}
+
+// failure is yielded by the async func to fail the task
+type failure struct{ err error }
+
+func (failure) OnCompleted(func()) {}
+
+// Fail fails the async func with err by Yield(Fail(err)), the async func is not resumed,
+// and the pending deferred calls are called
+func Fail(err error) Awaitable {
+	return failure{err}
+}
+
+// Sleep returns the task completed after d, or failed with the error of ctx if cancelled
+func Sleep(l *Loop, ctx context.Context, d time.Duration) *Task[struct{}] {
+	t := newTask[struct{}](l, ctx)
+	tm := l.after(d, func() { t.complete(struct{}{}, nil) })
+	t.onCancel = func() {
+		l.stopTimer(tm)
+		t.complete(struct{}{}, t.ctx.Err())
+	}
+	return t
+}
+
+// FromCallback adapts the callback style async api to the task,
+// f is called on the loop, and done can be called from any goroutine only once,
+// the loop keeps running until done called, even if the task is cancelled
+func FromCallback[T any](l *Loop, ctx context.Context, f func(done func(T, error))) *Task[T] {
+	t := newTask[T](l, ctx)
+	t.onCancel = func() {
+		var zero T
+		t.complete(zero, t.ctx.Err())
+	}
+	l.hold()
+	l.enqueue(func() {
+		f(func(v T, err error) {
+			l.Post(func() {
+				l.release()
+				t.complete(v, err)
+			})
+		})
+	})
+	return t
+}
+
+// WhenAll returns the task completed after all ts completed, with the results in order,
+// or failed with the first error in order of completion
+func WhenAll[T any](l *Loop, ts ...*Task[T]) *Task[[]T] {
+	all := newTask[[]T](l, context.Background())
+	all.onCancel = func() { all.complete(nil, all.ctx.Err()) }
+
+	var err error
+	n := len(ts)
+	k := func(t *Task[T]) func() {
+		return func() {
+			if _, e := t.Result(); e != nil && err == nil {
+				err = e
+			}
+			if n--; n > 0 {
+				return
+			}
+			if err != nil {
+				all.complete(nil, err)
+				return
+			}
+			xs := make([]T, len(ts))
+			for i, t := range ts {
+				xs[i] = t.val
+			}
+			all.complete(xs, nil)
+		}
+	}
+
+	if n == 0 {
+		all.complete([]T{}, nil)
+	}
+	for _, t := range ts {
+		t.OnCompleted(k(t))
+	}
+	return all
+}
+
+var errNoTask = errors.New("async: WhenAny of no task")
+
+// WhenAny returns the task completed after any of ts completed, with the index of it,
+// the others keep running
+func WhenAny[T any](l *Loop, ts ...*Task[T]) *Task[int] {
+	first := newTask[int](l, context.Background())
+	first.onCancel = func() { first.complete(-1, first.ctx.Err()) }
+
+	if len(ts) == 0 {
+		first.complete(-1, errNoTask)
+	}
+	for i, t := range ts {
+		i := i
+		t.OnCompleted(func() { first.complete(i, nil) })
+	}
+	return first
+}
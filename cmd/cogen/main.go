@@ -1,21 +1,132 @@
+// Cogen rewrites the co files, i.e., *_co.go and *_co_test.go, to the go files.
+//
+// Usage:
+//
+//	cogen [flags] [packages]
+//
+// The packages are the go list patterns relative to the current dir, "./..." by default,
+// it runs in go:generate mode as well
+//
+//	//go:generate cogen
 package main
 
 import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/goghcrow/go-co/rewriter"
+	"github.com/goghcrow/go-loader"
+)
+
+const (
+	exitOK      = 0
+	exitFailure = 1 // failed to rewrite, or the generated files are stale
+	exitInvalid = 2 // the flags are invalid, or the co files are not well typed
 )
 
 func main() {
-	goFile := os.Getenv("GOFILE")
-	if goFile == "" {
-		panic("Must run in go:generate mode")
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) (code int) {
+	fs := flag.NewFlagSet("cogen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var (
+		suffix  = fs.String("suffix", "co", "file suffix of the co files, i.e., *_{suffix}.go")
+		tag     = fs.String("tags", "co", "build tag of the co files, negated in the generated files")
+		output  = fs.String("o", "", "output dir of the generated files, beside the co files by default")
+		machine = fs.Bool("sm", false, "compile the yield func to state machine")
+		lines   = fs.Bool("line", false, "emit the //line directives of the co files in the generated files")
+		check   = fs.Bool("check", false, "print the diff of the stale generated files, and exit with 1 if any, without writing")
+		verbose = fs.Bool("v", false, "print the progress")
+	)
+	fs.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, "usage: cogen [flags] [packages]\n\nflags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitInvalid
 	}
 
 	cwd, err := os.Getwd()
 	if err != nil {
-		panic(err)
+		_, _ = fmt.Fprintf(stderr, "cogen: %v\n", err)
+		return exitFailure
+	}
+
+	opts := []rewriter.Option{
+		rewriter.WithFileSuffix(*suffix),
+		rewriter.WithBuildTag(*tag),
+		// the type errors are reported by report instead of the loader
+		rewriter.WithTypeCheck(),
+		rewriter.WithLoaderOptions(loader.WithSuppressErrors()),
+	}
+	if patterns := fs.Args(); len(patterns) > 0 {
+		opts = append(opts, rewriter.WithPatterns(patterns...))
+	}
+	if *output != "" {
+		opts = append(opts, rewriter.WithOutputDir(*output))
+	}
+	if *machine {
+		opts = append(opts, rewriter.WithStateMachine())
+	}
+	if *lines {
+		opts = append(opts, rewriter.WithLineDirectives())
+	}
+	if !*verbose {
+		log.SetOutput(io.Discard)
+		defer log.SetOutput(os.Stderr)
 	}
 
-	rewriter.GoGen(cwd)
+	defer func() {
+		if p := recover(); p != nil {
+			_, _ = fmt.Fprintf(stderr, "cogen: %v\n", p)
+			code = exitFailure
+		}
+	}()
+	if *check {
+		stale, err := rewriter.Check(cwd, stdout, opts...)
+		if err != nil {
+			return report(stderr, err)
+		}
+		if len(stale) > 0 {
+			_, _ = fmt.Fprintf(stderr, "cogen: %d stale generated files, run cogen to update\n", len(stale))
+			return exitFailure
+		}
+		return exitOK
+	}
+	if err := rewriter.GoGen(cwd, opts...); err != nil {
+		return report(stderr, err)
+	}
+	return exitOK
+}
+
+// report prints the diagnostics one per line, in the form of file:line:col: msg,
+// the file is relative to the current dir if possible, and returns the exit code
+func report(w io.Writer, err error) int {
+	ds, ok := err.(rewriter.Diagnostics)
+	if !ok {
+		_, _ = fmt.Fprintf(w, "cogen: %v\n", err)
+		return exitFailure
+	}
+	code := exitFailure
+	cwd, _ := os.Getwd()
+	for _, d := range ds {
+		if d.Code == rewriter.CodeTypeError {
+			code = exitInvalid
+		}
+		if rel, err := filepath.Rel(cwd, d.Pos.Filename); err == nil && !strings.HasPrefix(rel, "..") {
+			d.Pos.Filename = rel
+		}
+		_, _ = fmt.Fprintln(w, d.Error())
+	}
+	return code
 }
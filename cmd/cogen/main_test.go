@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
+func TestExitCode(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		dir    string
+		args   []string
+		code   int
+		stderr string
+	}{
+		{"help", ".", []string{"-h"}, exitOK, "usage: cogen"},
+		{"invalid flag", ".", []string{"-x"}, exitInvalid, "flag provided but not defined: -x"},
+		{"no co package", "../../seq", nil, exitOK, ""},
+		{"pattern matching nothing", "../..", []string{"./seq/nothing..."}, exitOK, ""},
+		{"pattern not found", "../..", []string{"./nothing/..."}, exitInvalid, "pattern ./nothing/...: lstat ./nothing/: no such file or directory"},
+		{"check no co package", "../../seq", []string{"-check"}, exitOK, ""},
+		{"diagnostics", "testdata/diag", []string{"-o", t.TempDir()}, exitFailure, "diag_co.go:11:3: invalid yield func signature"},
+		{"type errors", "testdata/illtyped", []string{"-o", t.TempDir()}, exitInvalid, "illtyped_co.go:11:9: invalid operation"},
+		{"check type errors", "testdata/illtyped", []string{"-check"}, exitInvalid, "illtyped_co.go:11:9: invalid operation"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			chdir(t, tt.dir)
+			var stdout, stderr strings.Builder
+			if code := run(tt.args, &stdout, &stderr); code != tt.code {
+				t.Fatalf("expect exit %d got %d: %s%s", tt.code, code, stdout.String(), stderr.String())
+			}
+			if !strings.Contains(stderr.String(), tt.stderr) {
+				t.Fatalf("expect stderr containing %q got %q", tt.stderr, stderr.String())
+			}
+			if tt.stderr == "" && stderr.Len() > 0 {
+				t.Fatalf("expect no stderr got %q", stderr.String())
+			}
+		})
+	}
+}
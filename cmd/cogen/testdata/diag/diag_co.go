@@ -0,0 +1,14 @@
+//go:build co
+
+package diag
+
+import (
+	. "github.com/goghcrow/go-co"
+)
+
+func Goroutine() Iter[int] {
+	go func() {
+		Yield(1)
+	}()
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build co
+
+package illtyped
+
+import (
+	. "github.com/goghcrow/go-co"
+)
+
+func Count(n int) Iter[int] {
+	for i := 0; i < n; i++ {
+		Yield(i + "")
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+// Covet reports the co source not supported by cogen, by rewriter.Analyzer,
+// the co files are analyzed with the build tag co.
+//
+// Usage:
+//
+//	GOFLAGS=-tags=co covet [packages]
+//
+// or as the vet tool, which requires the co files well typed
+//
+//	go vet -vettool=$(which covet) -tags co [packages]
+package main
+
+import (
+	"github.com/goghcrow/go-co/rewriter"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(rewriter.Analyzer)
+}
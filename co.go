@@ -1,5 +1,7 @@
 package co
 
+import "github.com/goghcrow/go-co/seq"
+
 // Iter is a 𝗦𝘆𝗻𝘁𝗮𝗰𝘁𝗶𝗰 𝗦𝘂𝗴𝗮𝗿
 // please coding depending on the type parameter [V]
 // instead of the underlying type <-chan
@@ -8,5 +10,92 @@ type Iter[V any] <-chan V
 func (Iter[V]) MoveNext() (_ bool) { return }
 func (Iter[V]) Current() (_ V)     { return }
 
-func Yield[V any](V)           {}
+// Send resumes the generator, the suspended yield expression returns v
+func (Iter[V]) Send(v V) (yield V, ok bool) { return }
+
+// Close stops the generator early, the pending deferred calls are called,
+// MoveNext returns false after closed,
+// for range calls Close automatically when the loop exits early
+func (Iter[V]) Close() (_ error) { return }
+
+// Catch makes the generator finish instead of panicking when the panic occurs,
+// the panic is available by Err, e.g.,
+//
+//	it := gen().Catch()
+//	for v := range it { ... }
+//	if err := it.Err(); err != nil { ... }
+func (Iter[V]) Catch() (_ Iter[V]) { return }
+
+// Err is checked after the loop, like bufio.Scanner,
+// returns the error returned by ErrIter generator,
+// or the *seq.PanicError caught if the generator finished by panicking,
+// the panic raised in the generator is re-panicked as *seq.PanicError if not caught
+func (Iter[V]) Err() (_ error) { return }
+
+// ErrIter is an Iter which can fail,
+// yield func returning ErrIter[V] can `return Return[V](err)`,
+// which finishes the generator and is available by Err() after the loop
+type ErrIter[V any] <-chan V
+
+func (ErrIter[V]) MoveNext() (_ bool)          { return }
+func (ErrIter[V]) Current() (_ V)              { return }
+func (ErrIter[V]) Send(v V) (yield V, ok bool) { return }
+func (ErrIter[V]) Close() (_ error)            { return }
+func (ErrIter[V]) Catch() (_ ErrIter[V])       { return }
+func (ErrIter[V]) Err() (_ error)              { return }
+
+// Iter2 yields key-value pairs by Yield2(k, v),
+// ranged over by `for k, v := range it`,
+// the underlying type is chosen for type checking the range stmt only,
+// which requires go1.23
+type Iter2[K, V any] func(yield func(K, V) bool)
+
+func (Iter2[K, V]) MoveNext() (_ bool)          { return }
+func (Iter2[K, V]) Current() (_ seq.Pair[K, V]) { return }
+func (Iter2[K, V]) Close() (_ error)            { return }
+func (Iter2[K, V]) Catch() (_ Iter2[K, V])      { return }
+func (Iter2[K, V]) Err() (_ error)              { return }
+
+// Generator is an Iter with the result of type R,
+// yield func returning Generator[Y, R] can `return Return[Y](r)`,
+// which is available by Result() after exhaustion
+type Generator[Y, R any] <-chan Y
+
+func (Generator[Y, R]) MoveNext() (_ bool)          { return }
+func (Generator[Y, R]) Current() (_ Y)              { return }
+func (Generator[Y, R]) Send(v Y) (yield Y, ok bool) { return }
+func (Generator[Y, R]) Result() (_ R)               { return }
+func (Generator[Y, R]) Close() (_ error)            { return }
+func (Generator[Y, R]) Catch() (_ Generator[Y, R])  { return }
+func (Generator[Y, R]) Err() (_ error)              { return }
+
+// Coroutine yields Y, receives S by Send, and returns R by `return Return[Y](r)`,
+// the sent value is received by `x := YieldRecv[S](y)`
+type Coroutine[Y, S, R any] <-chan Y
+
+func (Coroutine[Y, S, R]) MoveNext() (_ bool)            { return }
+func (Coroutine[Y, S, R]) Current() (_ Y)                { return }
+func (Coroutine[Y, S, R]) Send(v S) (yield Y, ok bool)   { return }
+func (Coroutine[Y, S, R]) Result() (_ R)                 { return }
+func (Coroutine[Y, S, R]) Close() (_ error)              { return }
+func (Coroutine[Y, S, R]) Catch() (_ Coroutine[Y, S, R]) { return }
+func (Coroutine[Y, S, R]) Err() (_ error)                { return }
+
+// Yield returns the value passed by Send, or zero value if resumed by MoveNext
+// only supported in the form of `Yield(v)`, `x := Yield(v)` or `x = Yield(v)`
+func Yield[V any](V) (_ V) { return }
+
+// YieldRecv is Yield receiving the value of type S, used in Coroutine
+func YieldRecv[S, Y any](Y) (_ S) { return }
+
+// Yield2 yields the key-value pair, used in Iter2
+func Yield2[K, V any](K, V) {}
+
+// YieldFrom yields all elements of the delegated Iter, driving it directly,
+// the sent values are passed to it, and its Err() is returned after exhaustion
 func YieldFrom[V any](Iter[V]) {}
+
+// Return returns the result r of the yield func returning Generator[Y, R] or Coroutine[Y, S, R],
+// or the error of ErrIter[Y], only supported in the form of `return Return[Y](r)`,
+// Y is required for type checking, which is the same as the yield type
+func Return[Y, R any](r R) (_ <-chan Y) { return }
@@ -1,10 +1,5 @@
 package co
 
-// func (*Iter[V]) Send(V) (yield V, ok bool) { return }
-// func (*Iter[V]) Result() (_ V)             { return }
-
-// ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
-
 // type Rangeable[V any] interface { Range() Iter[V] }
 // type RangeFn[V any] func() Iter[V]
 // func (r RangeFn[V]) Range() Iter[V] { return r() }
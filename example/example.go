@@ -39,23 +39,11 @@ func SampleGetNumList() (_ ʂɘʠ.Iterator[int]) {
 func SampleYieldFrom() (_ ʂɘʠ.Iterator[int]) {
 	return ʂɘʠ.Start[int](
 		ʂɘʠ.Bind[int](0, func() ʂɘʠ.Seq[int] {
-			return ʂɘʠ.Combine[int](
-				ʂɘʠ.Delay[int](func() ʂɘʠ.Seq[int] {
-					ɪʇ := SampleGetNumList()
-					return ʂɘʠ.While[int](
-						ɪʇ.MoveNext,
-						ʂɘʠ.Delay[int](func() ʂɘʠ.Seq[int] {
-							ʌ := ɪʇ.Current()
-							return ʂɘʠ.Bind[int](ʌ,
-								ʂɘʠ.Normal[int],
-							)
-						}))
-				}),
-
-				ʂɘʠ.Bind[int](4,
+			return ʂɘʠ.BindFrom[int](SampleGetNumList(), func() ʂɘʠ.Seq[int] {
+				return ʂɘʠ.Bind[int](4,
 					ʂɘʠ.Return[int],
-				),
-			)
+				)
+			})
 		}),
 	)
 
@@ -319,10 +307,11 @@ func Run() {
 		n := ɪʇ.Current()
 		println(n)
 	}
-	for ɪʇ := Fibonacci(); ɪʇ.MoveNext(); {
-		n := ɪʇ.Current()
+	for ɪʇᶜ1 := Fibonacci(); ɪʇᶜ1.MoveNext(); {
+		n := ɪʇᶜ1.Current()
 		if n > 1000 {
 			println(n)
+			ʂɘʠ.Close[int](ɪʇᶜ1)
 			break
 		}
 	}
@@ -400,3 +389,48 @@ func ReadFile(name string) (_ ʂɘʠ.Iterator[Line]) {
 	}))
 
 }
+
+// ReadLines is ReadFile without smuggling the error through the yielded value,
+// the error is available by Err() after the loop
+// // ReadLines is ReadFile without smuggling the error through the yielded value,
+// // the error is available by Err() after the loop
+//
+//	func ReadLines(name string) ErrIter[string] {
+//		file, err := os.Open(name)
+//		if err != nil {
+//			return Return[string](err)
+//		}
+//		defer file.Close()
+//
+//		sc := bufio.NewScanner(file)
+//		for sc.Scan() {
+//			Yield(sc.Text())
+//		}
+//		return Return[string](sc.Err())
+//	}
+func ReadLines(name string) ʂɘʠ.Iterator[string] {
+	return ʂɘʠ.Start[string](ʂɘʠ.Delay[string](func() ʂɘʠ.Seq[string] {
+		file, err := os.Open(name)
+		if err != nil {
+			return ʂɘʠ.ReturnError[string](err)
+		}
+		ɗ := file.Close
+		return ʂɘʠ.Defer[string](func() {
+			ɗ()
+		}, func() ʂɘʠ.Seq[string] {
+
+			sc := bufio.NewScanner(file)
+			return ʂɘʠ.Combine[string](
+				ʂɘʠ.While[string](
+					sc.Scan,
+					ʂɘʠ.Delay[string](func() ʂɘʠ.Seq[string] {
+						return ʂɘʠ.Bind[string](sc.Text(),
+							ʂɘʠ.Normal[string],
+						)
+					})),
+				ʂɘʠ.Delay[string](func() ʂɘʠ.Seq[string] {
+					return ʂɘʠ.ReturnError[string](sc.Err())
+				}))
+		})
+	}))
+}
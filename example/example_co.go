@@ -154,3 +154,19 @@ func ReadFile(name string) (_ Iter[Line]) {
 	}
 	return
 }
+
+// ReadLines is ReadFile without smuggling the error through the yielded value,
+// the error is available by Err() after the loop
+func ReadLines(name string) ErrIter[string] {
+	file, err := os.Open(name)
+	if err != nil {
+		return Return[string](err)
+	}
+	defer file.Close()
+
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		Yield(sc.Text())
+	}
+	return Return[string](sc.Err())
+}
@@ -3,6 +3,7 @@
 package example
 
 import (
+	"os"
 	"reflect"
 	"testing"
 
@@ -21,6 +22,20 @@ func TestSampleMap(t *testing.T) {
 	assertEqual(t, m["c"], 3)
 }
 
+func TestReadLines(t *testing.T) {
+	it := ReadLines("example_co.go")
+	n := 0
+	for range it {
+		n++
+	}
+	assertEqual(t, n > 0, true)
+	assertEqual(t, it.Err(), nil)
+
+	it = ReadLines("not_exist")
+	assertEqual(t, it.MoveNext(), false)
+	assertEqual(t, os.IsNotExist(it.Err()), true)
+}
+
 func TestSample(t *testing.T) {
 	all := []struct {
 		name    string
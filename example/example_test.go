@@ -5,6 +5,7 @@ package example
 
 import (
 	ʂɘʠ "github.com/goghcrow/go-co/seq"
+	"os"
 	"reflect"
 	"testing"
 )
@@ -21,6 +22,20 @@ func TestSampleMap(t *testing.T) {
 	assertEqual(t, m["c"], 3)
 }
 
+func TestReadLines(t *testing.T) {
+	it := ReadLines("example_co.go")
+	n := 0
+	for ɪʇ := it; ɪʇ.MoveNext(); {
+		n++
+	}
+	assertEqual(t, n > 0, true)
+	assertEqual(t, it.Err(), nil)
+
+	it = ReadLines("not_exist")
+	assertEqual(t, it.MoveNext(), false)
+	assertEqual(t, os.IsNotExist(it.Err()), true)
+}
+
 func TestSample(t *testing.T) {
 	all := []struct {
 		name    string
@@ -88,15 +103,16 @@ func TestSample(t *testing.T) {
 				return ʂɘʠ.Start[int](
 					ʂɘʠ.Combine[int](
 						ʂɘʠ.Delay[int](func() ʂɘʠ.Seq[int] {
-							ɪʇ := Fibonacci()
+							ɪʇᶜ1 := Fibonacci()
 							return ʂɘʠ.While[int](
-								ɪʇ.MoveNext,
+								ɪʇᶜ1.MoveNext,
 								ʂɘʠ.Delay[int](func() ʂɘʠ.Seq[int] {
-									n := ɪʇ.Current()
+									n := ɪʇᶜ1.Current()
 									if n > 1000 {
-										return ʂɘʠ.Bind[int](n,
-											ʂɘʠ.Break[int],
-										)
+										return ʂɘʠ.Bind[int](n, func() ʂɘʠ.Seq[int] {
+											ʂɘʠ.Close[int](ɪʇᶜ1)
+											return ʂɘʠ.Break[int]()
+										})
 									}
 									return ʂɘʠ.Normal[int]()
 								}))
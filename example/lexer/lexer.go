@@ -27,23 +27,11 @@ func MkLexer(fn StateFn) Lexer {
 	// 	return
 	// }
 	(src string) (_ ʂɘʠ.Iterator[Tok]) {
-		return ʂɘʠ.Start[Tok](
-			ʂɘʠ.Combine[Tok](
-				ʂɘʠ.Delay[Tok](func() ʂɘʠ.Seq[Tok] {
-					ɪʇ := fn(&L{src: src})
-					return ʂɘʠ.While[Tok](
-						ɪʇ.MoveNext,
-						ʂɘʠ.Delay[Tok](func() ʂɘʠ.Seq[Tok] {
-							ʌ := ɪʇ.Current()
-							return ʂɘʠ.Bind[Tok](ʌ,
-								ʂɘʠ.Normal[Tok],
-							)
-						}))
-				}),
-
-				ʂɘʠ.Return[Tok](),
-			),
-		)
+		return ʂɘʠ.Start[Tok](ʂɘʠ.Delay[Tok](func() ʂɘʠ.Seq[Tok] {
+			return ʂɘʠ.BindFrom[Tok](fn(&L{src: src}),
+				ʂɘʠ.Return[Tok],
+			)
+		}))
 
 	}
 }
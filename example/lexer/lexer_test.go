@@ -36,20 +36,8 @@ func TestLexer(t *testing.T) {
 				if l.Consume(unicode.IsSpace) {
 					l.Skip()
 				}
-				return ʂɘʠ.Combine[Tok](
-					ʂɘʠ.Delay[Tok](func() ʂɘʠ.Seq[Tok] {
-						ɪʇ := lexNum(l)
-						return ʂɘʠ.While[Tok](
-							ɪʇ.MoveNext,
-							ʂɘʠ.Delay[Tok](func() ʂɘʠ.Seq[Tok] {
-								ʌ := ɪʇ.Current()
-								return ʂɘʠ.Bind[Tok](ʌ,
-									ʂɘʠ.Normal[Tok],
-								)
-							}))
-					}),
-
-					ʂɘʠ.Return[Tok](),
+				return ʂɘʠ.BindFrom[Tok](lexNum(l),
+					ʂɘʠ.Return[Tok],
 				)
 			}))
 
@@ -88,23 +76,11 @@ func TestLexer(t *testing.T) {
 						}
 					}
 					return ʂɘʠ.Normal[Tok]()
-				}),
-					ʂɘʠ.Combine[Tok](
-						ʂɘʠ.Delay[Tok](func() ʂɘʠ.Seq[Tok] {
-							ɪʇ := lexStr(l)
-							return ʂɘʠ.While[Tok](
-								ɪʇ.MoveNext,
-								ʂɘʠ.Delay[Tok](func() ʂɘʠ.Seq[Tok] {
-									ʌ := ɪʇ.Current()
-									return ʂɘʠ.Bind[Tok](ʌ,
-										ʂɘʠ.Normal[Tok],
-									)
-								}))
-						}),
-
-						ʂɘʠ.Return[Tok](),
-					),
-				),
+				}), ʂɘʠ.Delay[Tok](func() ʂɘʠ.Seq[Tok] {
+					return ʂɘʠ.BindFrom[Tok](lexStr(l),
+						ʂɘʠ.Return[Tok],
+					)
+				})),
 			)
 
 		}
@@ -124,23 +100,11 @@ func TestLexer(t *testing.T) {
 						)
 					}
 					return ʂɘʠ.Normal[Tok]()
-				}),
-					ʂɘʠ.Combine[Tok](
-						ʂɘʠ.Delay[Tok](func() ʂɘʠ.Seq[Tok] {
-							ɪʇ := lexWS(l)
-							return ʂɘʠ.While[Tok](
-								ɪʇ.MoveNext,
-								ʂɘʠ.Delay[Tok](func() ʂɘʠ.Seq[Tok] {
-									ʌ := ɪʇ.Current()
-									return ʂɘʠ.Bind[Tok](ʌ,
-										ʂɘʠ.Normal[Tok],
-									)
-								}))
-						}),
-
-						ʂɘʠ.Return[Tok](),
-					),
-				),
+				}), ʂɘʠ.Delay[Tok](func() ʂɘʠ.Seq[Tok] {
+					return ʂɘʠ.BindFrom[Tok](lexWS(l),
+						ʂɘʠ.Return[Tok],
+					)
+				})),
 			)
 
 		}
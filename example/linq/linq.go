@@ -110,17 +110,9 @@ func SelectMany[A, R any](it ʂɘʠ.Iterator[A], f func(A) ʂɘʠ.Iterator[R]) (
 					ɪʇ.MoveNext,
 					ʂɘʠ.Delay[R](func() ʂɘʠ.Seq[R] {
 						a := ɪʇ.Current()
-						return ʂɘʠ.Delay[R](func() ʂɘʠ.Seq[R] {
-							ɪʇ := f(a)
-							return ʂɘʠ.While[R](
-								ɪʇ.MoveNext,
-								ʂɘʠ.Delay[R](func() ʂɘʠ.Seq[R] {
-									ʌ := ɪʇ.Current()
-									return ʂɘʠ.Bind[R](ʌ,
-										ʂɘʠ.Normal[R],
-									)
-								}))
-						})
+						return ʂɘʠ.BindFrom[R](f(a),
+							ʂɘʠ.Normal[R],
+						)
 					}))
 			}),
 
@@ -197,9 +189,10 @@ func First[A any](it ʂɘʠ.Iterator[A]) (a A, has bool) {
 }
 
 func FirstWhile[A any](it ʂɘʠ.Iterator[A], p Predicate[A]) (fst A, has bool) {
-	for ɪʇ := it; ɪʇ.MoveNext(); {
-		a := ɪʇ.Current()
+	for ɪʇᶜ1 := it; ɪʇᶜ1.MoveNext(); {
+		a := ɪʇᶜ1.Current()
 		if p(a) {
+			ʂɘʠ.Close[A](ɪʇᶜ1)
 			return a, true
 		}
 	}
@@ -243,12 +236,13 @@ func Take[A any](it ʂɘʠ.Iterator[A], cnt int) (_ ʂɘʠ.Iterator[A]) {
 	return ʂɘʠ.Start[A](
 		ʂɘʠ.Combine[A](
 			ʂɘʠ.Delay[A](func() ʂɘʠ.Seq[A] {
-				ɪʇ := it
+				ɪʇᶜ2 := it
 				return ʂɘʠ.While[A](
-					ɪʇ.MoveNext,
+					ɪʇᶜ2.MoveNext,
 					ʂɘʠ.Delay[A](func() ʂɘʠ.Seq[A] {
-						a := ɪʇ.Current()
+						a := ɪʇᶜ2.Current()
 						if cnt <= 0 {
+							ʂɘʠ.Close[A](ɪʇᶜ2)
 							return ʂɘʠ.Break[A]()
 
 						}
@@ -386,9 +380,10 @@ func Reduce[A any](
 }
 
 func All[A any](it ʂɘʠ.Iterator[A], p Predicate[A]) bool {
-	for ɪʇ := it; ɪʇ.MoveNext(); {
-		a := ɪʇ.Current()
+	for ɪʇᶜ3 := it; ɪʇᶜ3.MoveNext(); {
+		a := ɪʇᶜ3.Current()
 		if !p(a) {
+			ʂɘʠ.Close[A](ɪʇᶜ3)
 			return false
 		}
 	}
@@ -401,9 +396,10 @@ func AnyElem[A any](it ʂɘʠ.Iterator[A]) bool {
 }
 
 func Any[A any](it ʂɘʠ.Iterator[A], p Predicate[A]) bool {
-	for ɪʇ := it; ɪʇ.MoveNext(); {
-		a := ɪʇ.Current()
+	for ɪʇᶜ4 := it; ɪʇᶜ4.MoveNext(); {
+		a := ɪʇᶜ4.Current()
 		if p(a) {
+			ʂɘʠ.Close[A](ɪʇᶜ4)
 			return true
 		}
 	}
@@ -417,24 +413,12 @@ func Any[A any](it ʂɘʠ.Iterator[A], p Predicate[A]) bool {
 //		return
 //	}
 func Append[A any](it ʂɘʠ.Iterator[A], a A) (_ ʂɘʠ.Iterator[A]) {
-	return ʂɘʠ.Start[A](
-		ʂɘʠ.Combine[A](
-			ʂɘʠ.Delay[A](func() ʂɘʠ.Seq[A] {
-				ɪʇ := it
-				return ʂɘʠ.While[A](
-					ɪʇ.MoveNext,
-					ʂɘʠ.Delay[A](func() ʂɘʠ.Seq[A] {
-						ʌ := ɪʇ.Current()
-						return ʂɘʠ.Bind[A](ʌ,
-							ʂɘʠ.Normal[A],
-						)
-					}))
-			}),
-			ʂɘʠ.Delay[A](func() ʂɘʠ.Seq[A] {
-				return ʂɘʠ.Bind[A](a,
-					ʂɘʠ.Return[A],
-				)
-			})),
-	)
+	return ʂɘʠ.Start[A](ʂɘʠ.Delay[A](func() ʂɘʠ.Seq[A] {
+		return ʂɘʠ.BindFrom[A](it, func() ʂɘʠ.Seq[A] {
+			return ʂɘʠ.Bind[A](a,
+				ʂɘʠ.Return[A],
+			)
+		})
+	}))
 
 }
@@ -6,6 +6,7 @@ package linq
 import (
 	ʂɘʠ "github.com/goghcrow/go-co/seq"
 	"reflect"
+	"runtime/debug"
 	"strconv"
 	"strings"
 	"testing"
@@ -427,3 +428,18 @@ func TestCrossJoin(t *testing.T) {
 		{"c", 1}, T{"c", 2}, T{"c", 3},
 	})
 }
+
+func TestWhereStackSafe(t *testing.T) {
+
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+	xs := Where(Range(0, 1_000_000), func(x int) bool { return x%500_000 == 0 })
+	assertEqual(t, ToSlice(xs), []int{0, 500_000})
+}
+
+func BenchmarkWhereSkipping(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		xs := Where(Range(0, 10_000), func(x int) bool { return x == 9_999 })
+		for xs.MoveNext() {
+		}
+	}
+}
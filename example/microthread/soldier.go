@@ -43,17 +43,9 @@ func (s *Soldier) Patrol() (_ ʂɘʠ.Iterator[State]) {
 				s.Alive,
 				ʂɘʠ.Delay[State](func() ʂɘʠ.Seq[State] {
 					if s.CanSeeTarget() {
-						return ʂɘʠ.Delay[State](func() ʂɘʠ.Seq[State] {
-							ɪʇ := s.Attack()
-							return ʂɘʠ.While[State](
-								ɪʇ.MoveNext,
-								ʂɘʠ.Delay[State](func() ʂɘʠ.Seq[State] {
-									ʌ := ɪʇ.Current()
-									return ʂɘʠ.Bind[State](ʌ,
-										ʂɘʠ.Normal[State],
-									)
-								}))
-						})
+						return ʂɘʠ.BindFrom[State](s.Attack(),
+							ʂɘʠ.Normal[State],
+						)
 					} else if s.InReloadStation() {
 						return ʂɘʠ.Bind[State](s.AnimateReload,
 							ʂɘʠ.Normal[State],
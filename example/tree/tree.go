@@ -55,92 +55,28 @@ func Walk[V any](n *Node[V], mode WalkMode) (_ ʂɘʠ.Iterator[V]) {
 			switch mode {
 			case PreOrder:
 				return ʂɘʠ.Bind[V](n.Val, func() ʂɘʠ.Seq[V] {
-					return ʂɘʠ.Combine[V](
-						ʂɘʠ.Delay[V](func() ʂɘʠ.Seq[V] {
-							ɪʇ := Walk(n.Left, mode)
-							return ʂɘʠ.While[V](
-								ɪʇ.MoveNext,
-								ʂɘʠ.Delay[V](func() ʂɘʠ.Seq[V] {
-									ʌ := ɪʇ.Current()
-									return ʂɘʠ.Bind[V](ʌ,
-										ʂɘʠ.Normal[V],
-									)
-								}))
-						}),
-
-						ʂɘʠ.Delay[V](func() ʂɘʠ.Seq[V] {
-							ɪʇ := Walk(n.Right, mode)
-							return ʂɘʠ.While[V](
-								ɪʇ.MoveNext,
-								ʂɘʠ.Delay[V](func() ʂɘʠ.Seq[V] {
-									ʌ := ɪʇ.Current()
-									return ʂɘʠ.Bind[V](ʌ,
-										ʂɘʠ.Normal[V],
-									)
-								}))
-						}),
-					)
+					return ʂɘʠ.BindFrom[V](Walk(n.Left, mode), func() ʂɘʠ.Seq[V] {
+						return ʂɘʠ.BindFrom[V](Walk(n.Right, mode),
+							ʂɘʠ.Normal[V],
+						)
+					})
 				})
 			case InOrder:
-				return ʂɘʠ.Combine[V](
-					ʂɘʠ.Delay[V](func() ʂɘʠ.Seq[V] {
-						ɪʇ := Walk(n.Left, mode)
-						return ʂɘʠ.While[V](
-							ɪʇ.MoveNext,
-							ʂɘʠ.Delay[V](func() ʂɘʠ.Seq[V] {
-								ʌ := ɪʇ.Current()
-								return ʂɘʠ.Bind[V](ʌ,
-									ʂɘʠ.Normal[V],
-								)
-							}))
-					}),
-					ʂɘʠ.Delay[V](func() ʂɘʠ.Seq[V] {
-						return ʂɘʠ.Bind[V](n.Val, func() ʂɘʠ.Seq[V] {
-							return ʂɘʠ.Delay[V](func() ʂɘʠ.Seq[V] {
-								ɪʇ := Walk(n.Right, mode)
-								return ʂɘʠ.While[V](
-									ɪʇ.MoveNext,
-									ʂɘʠ.Delay[V](func() ʂɘʠ.Seq[V] {
-										ʌ := ɪʇ.Current()
-										return ʂɘʠ.Bind[V](ʌ,
-											ʂɘʠ.Normal[V],
-										)
-									}))
-							})
-						})
-					}))
+				return ʂɘʠ.BindFrom[V](Walk(n.Left, mode), func() ʂɘʠ.Seq[V] {
+					return ʂɘʠ.Bind[V](n.Val, func() ʂɘʠ.Seq[V] {
+						return ʂɘʠ.BindFrom[V](Walk(n.Right, mode),
+							ʂɘʠ.Normal[V],
+						)
+					})
+				})
 			case PostOrder:
-				return ʂɘʠ.Combine[V](
-					ʂɘʠ.Delay[V](func() ʂɘʠ.Seq[V] {
-						ɪʇ := Walk(n.Left, mode)
-						return ʂɘʠ.While[V](
-							ɪʇ.MoveNext,
-							ʂɘʠ.Delay[V](func() ʂɘʠ.Seq[V] {
-								ʌ := ɪʇ.Current()
-								return ʂɘʠ.Bind[V](ʌ,
-									ʂɘʠ.Normal[V],
-								)
-							}))
-					}),
-
-					ʂɘʠ.Combine[V](
-						ʂɘʠ.Delay[V](func() ʂɘʠ.Seq[V] {
-							ɪʇ := Walk(n.Right, mode)
-							return ʂɘʠ.While[V](
-								ɪʇ.MoveNext,
-								ʂɘʠ.Delay[V](func() ʂɘʠ.Seq[V] {
-									ʌ := ɪʇ.Current()
-									return ʂɘʠ.Bind[V](ʌ,
-										ʂɘʠ.Normal[V],
-									)
-								}))
-						}),
-						ʂɘʠ.Delay[V](func() ʂɘʠ.Seq[V] {
-							return ʂɘʠ.Bind[V](n.Val,
-								ʂɘʠ.Normal[V],
-							)
-						})),
-				)
+				return ʂɘʠ.BindFrom[V](Walk(n.Left, mode), func() ʂɘʠ.Seq[V] {
+					return ʂɘʠ.BindFrom[V](Walk(n.Right, mode), func() ʂɘʠ.Seq[V] {
+						return ʂɘʠ.Bind[V](n.Val,
+							ʂɘʠ.Normal[V],
+						)
+					})
+				})
 			default:
 
 				panic("unknown walk mode")
@@ -87,6 +87,31 @@ func TestTreeMatcher(t *testing.T) {
 	}
 }
 
+func TestWalkDeep(t *testing.T) {
+	// the elements of the nested delegation are yielded by the innermost directly,
+	// walking a degenerate tree costs O(n) instead of O(n^2)
+	const n = 10_000
+	assertEqual(t, len(iter2slice(Walk(chain(n), InOrder))), n)
+}
+
+func BenchmarkWalkDeep(b *testing.B) {
+	root := chain(1_000)
+	for i := 0; i < b.N; i++ {
+		it := Walk(root, InOrder)
+		for it.MoveNext() {
+		}
+	}
+}
+
+// the left-leaning tree of depth n
+func chain(n int) *Node[int] {
+	var root *Node[int]
+	for i := 0; i < n; i++ {
+		root = &Node[int]{Val: i, Left: root}
+	}
+	return root
+}
+
 func iter2slice[V any](g Iter[V]) (xs []V) {
 	for i := range g {
 		xs = append(xs, i)
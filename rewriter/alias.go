@@ -0,0 +1,8 @@
+//go:build !go1.22
+
+package rewriter
+
+import "go/types"
+
+// unalias returns t as is, types.Alias is introduced by go1.22
+func unalias(t types.Type) types.Type { return t }
@@ -0,0 +1,8 @@
+//go:build go1.22
+
+package rewriter
+
+import "go/types"
+
+// unalias returns the actual type of the alias, which is materialized since go1.22
+func unalias(t types.Type) types.Type { return types.Unalias(t) }
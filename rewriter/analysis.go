@@ -0,0 +1,203 @@
+package rewriter
+
+import (
+	"go/ast"
+	"go/types"
+	"io"
+	"log"
+	"reflect"
+
+	"github.com/goghcrow/go-ast-matcher"
+	"github.com/goghcrow/go-imports"
+	"github.com/goghcrow/go-loader"
+	"github.com/goghcrow/go-matcher"
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+// Analyzer reports the co source not supported by the rewriter, which is the same as
+// the Diagnostics of GoGen, for go vet -vettool and gopls, the co files are analyzed
+// with the build tag co, e.g.,
+//
+//	go vet -vettool=$(which covet) -tags co ./...
+var Analyzer = &analysis.Analyzer{
+	Name: "co",
+	Doc: `report the co source not supported by cogen
+
+The co files, i.e., the files importing github.com/goghcrow/go-co, are checked by
+rewriting without writing, e.g., Yield called in the func not returning the iterator,
+including the goroutine and the callback, the iterator used as chan by <-, len or close,
+and the unsupported stmts, e.g., the var of the inexpressible type declared between
+the goto labels. The other files of the package are skipped.`,
+	URL:              "https://github.com/goghcrow/go-co",
+	Run:              runAnalyzer,
+	RunDespiteErrors: true,
+}
+
+func runAnalyzer(pass *analysis.Pass) (any, error) {
+	var coPkg *types.Package
+	for _, imp := range pass.Pkg.Imports() {
+		if imp.Path() == pkgCoPath {
+			coPkg = imp
+		}
+	}
+	if coPkg == nil {
+		return nil, nil
+	}
+
+	// the files are rewritten in place, which are shared by the other analyzers
+	c := astCloner{}
+	pkg := &packages.Package{
+		ID:         pass.Pkg.Path(),
+		Name:       pass.Pkg.Name(),
+		PkgPath:    pass.Pkg.Path(),
+		Fset:       pass.Fset,
+		Types:      pass.Pkg,
+		TypesSizes: pass.TypesSizes,
+	}
+	for _, f := range pass.Files {
+		pkg.CompiledGoFiles = append(pkg.CompiledGoFiles, pass.Fset.File(f.Pos()).Name())
+		pkg.Syntax = append(pkg.Syntax, c.clone(reflect.ValueOf(f)).Interface().(*ast.File))
+	}
+	pkg.TypesInfo = c.info(pass.TypesInfo)
+
+	l := &loader.Loader{
+		Flags: &loader.Flags{},
+		FSet:  pass.Fset,
+		Init:  []*packages.Package{pkg},
+		All: map[loader.PackagePath]*packages.Package{
+			pkg.PkgPath: pkg,
+			pkgCoPath:   {ID: pkgCoPath, Name: coPkg.Name(), PkgPath: pkgCoPath, Types: coPkg},
+		},
+		Gen: map[loader.FileName]loader.GenBy{},
+	}
+	r := mkRewriter(astmatcher.New(l, matcher.New()), defaultBuildTag)
+	r.logf = log.New(io.Discard, "", 0).Printf
+
+	l.VisitAllFiles(func(f *loader.File) {
+		if !imports.Uses(f, coPkg) {
+			return
+		}
+		defer func() {
+			if p := recover(); p != nil {
+				// the broken type info leads to the panics except the diagnostics
+				if len(pass.TypeErrors) == 0 {
+					r.diags = append(r.diags, mkDiagnostic(f.Package(), f.File.Name, CodeInternal, "%v", p))
+				}
+			}
+		}()
+		r.rewriteFile(f, func(string, *loader.File) {})
+	})
+
+	r.diags.sort()
+	for _, d := range r.diags {
+		pass.Report(analysis.Diagnostic{
+			Pos:      d.pos,
+			Category: string(d.Code),
+			Message:  d.Msg,
+		})
+	}
+	return nil, nil
+}
+
+// astCloner deep copies the ast with the positions kept, old node => new node,
+// the ast.Object and ast.Scope are shared, which are not used by the rewriter
+type astCloner map[ast.Node]ast.Node
+
+func (c astCloner) clone(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		switch v.Interface().(type) {
+		case *ast.Object, *ast.Scope:
+			return v
+		}
+		n, isNode := v.Interface().(ast.Node)
+		if isNode {
+			if x, ok := c[n]; ok {
+				return reflect.ValueOf(x)
+			}
+		}
+		x := reflect.New(v.Elem().Type())
+		if isNode {
+			c[n] = x.Interface().(ast.Node)
+		}
+		x.Elem().Set(c.clone(v.Elem()))
+		return x
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		x := reflect.New(v.Type()).Elem()
+		x.Set(c.clone(v.Elem()))
+		return x
+	case reflect.Struct:
+		x := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			x.Field(i).Set(c.clone(v.Field(i)))
+		}
+		return x
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		x := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			x.Index(i).Set(c.clone(v.Index(i)))
+		}
+		return x
+	}
+	return v
+}
+
+// info returns the type info of the cloned nodes
+func (c astCloner) info(info *types.Info) *types.Info {
+	x := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Instances:  map[*ast.Ident]types.Instance{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Implicits:  map[ast.Node]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+		Scopes:     map[ast.Node]*types.Scope{},
+		InitOrder:  info.InitOrder,
+	}
+	for k, v := range info.Types {
+		if n, ok := c[k]; ok {
+			x.Types[n.(ast.Expr)] = v
+		}
+	}
+	for k, v := range info.Instances {
+		if n, ok := c[k]; ok {
+			x.Instances[n.(*ast.Ident)] = v
+		}
+	}
+	for k, v := range info.Defs {
+		if n, ok := c[k]; ok {
+			x.Defs[n.(*ast.Ident)] = v
+		}
+	}
+	for k, v := range info.Uses {
+		if n, ok := c[k]; ok {
+			x.Uses[n.(*ast.Ident)] = v
+		}
+	}
+	for k, v := range info.Implicits {
+		if n, ok := c[k]; ok {
+			x.Implicits[n] = v
+		}
+	}
+	for k, v := range info.Selections {
+		if n, ok := c[k]; ok {
+			x.Selections[n.(*ast.SelectorExpr)] = v
+		}
+	}
+	for k, v := range info.Scopes {
+		if n, ok := c[k]; ok {
+			x.Scopes[n] = v
+		}
+	}
+	return x
+}
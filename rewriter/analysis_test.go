@@ -0,0 +1,71 @@
+package rewriter
+
+import (
+	"fmt"
+	"go/format"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestAnalyzer(t *testing.T) {
+	for _, dir := range []string{
+		"testdata/diag",
+		"testdata/mixed", // the plain files are skipped
+	} {
+		t.Run(dir, func(t *testing.T) {
+			testAnalyzer(t, dir)
+		})
+	}
+}
+
+func testAnalyzer(t *testing.T, dir string) {
+	pkgs, err := packages.Load(&packages.Config{
+		Mode:       overlayLoadMode,
+		Dir:        dir,
+		BuildFlags: []string{"-tags", defaultBuildTag},
+	}, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg := pkgs[0]
+
+	show := func() string {
+		var buf strings.Builder
+		for _, f := range pkg.Syntax {
+			_ = format.Node(&buf, pkg.Fset, f)
+		}
+		return buf.String()
+	}
+	src := show()
+
+	var got []string
+	pass := &analysis.Pass{
+		Analyzer:   Analyzer,
+		Fset:       pkg.Fset,
+		Files:      pkg.Syntax,
+		Pkg:        pkg.Types,
+		TypesInfo:  pkg.TypesInfo,
+		TypesSizes: pkg.TypesSizes,
+		TypeErrors: pkg.TypeErrors,
+		Report: func(d analysis.Diagnostic) {
+			pos := pkg.Fset.Position(d.Pos)
+			got = append(got, fmt.Sprintf("%s:%d:%d %s", filepath.Base(pos.Filename), pos.Line, pos.Column, d.Category))
+		},
+	}
+	_, err = Analyzer.Run(pass)
+	assertEqual(t, err, nil)
+
+	// the same as the diagnostics of GoGen
+	var expect []string
+	for _, d := range GoGen(dir, WithOutputDir(t.TempDir())).(Diagnostics) {
+		expect = append(expect, fmt.Sprintf("%s:%d:%d %s", filepath.Base(d.Pos.Filename), d.Pos.Line, d.Pos.Column, d.Code))
+	}
+	assertEqual(t, got, expect)
+
+	// the files shared by the other analyzers are not rewritten
+	assertEqual(t, show(), src)
+}
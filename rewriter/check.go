@@ -0,0 +1,279 @@
+package rewriter
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goghcrow/go-loader"
+	"golang.org/x/tools/go/packages"
+)
+
+// Check runs GoGen without touching the files, the generated files differing from
+// the files on disk are reported to w by unified diff, and returned relative to dir,
+// or the output dir if specified, so are the orphan generated files whose co files are removed,
+// the error is the same as GoGen
+func Check(dir string, w io.Writer, opts ...Option) (stale []string, err error) {
+	opt := mkOption(opts)
+	base := dir
+	if opt.outputDir != "" {
+		base = opt.outputDir
+	}
+	if base, err = filepath.Abs(base); err != nil {
+		return nil, err
+	}
+
+	outDirs, err := goGen(dir, opt, func(filename string, src []byte) error {
+		old, err := os.ReadFile(filename)
+		missing := os.IsNotExist(err)
+		if err != nil && !missing {
+			return err
+		}
+		if bytes.Equal(old, src) && !missing {
+			return nil
+		}
+
+		name, err := filepath.Rel(base, filename)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		stale = append(stale, name)
+
+		from := "a/" + name
+		if missing {
+			from = "/dev/null"
+		}
+		_, err = io.WriteString(w, unifiedDiff(from, "b/"+name, string(old), string(src)))
+		return err
+	})
+	if err != nil {
+		return
+	}
+
+	orphans, err := orphanFiles(outDirs, dir, base, opt.fileSuffix)
+	if err != nil {
+		return nil, err
+	}
+	for _, filename := range orphans {
+		old, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		name, err := filepath.Rel(base, filename)
+		if err != nil {
+			return nil, err
+		}
+		name = filepath.ToSlash(name)
+		stale = append(stale, name)
+		if _, err = io.WriteString(w, unifiedDiff("a/"+name, "/dev/null", string(old), "")); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(stale)
+	return
+}
+
+// orphanFiles returns the files generated by go-co in outDirs without the co files in dir,
+// only the output dirs of the loaded packages with co files are searched, so neither
+// the packages out of the patterns nor the outputs of Compile are taken for orphans
+func orphanFiles(outDirs []string, dir, base, suffix string) (orphans []string, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	for _, outDir := range outDirs {
+		entries, err := os.ReadDir(outDir)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if e.IsDir() || !strings.HasSuffix(name, ".go") {
+				continue
+			}
+			filename := filepath.Join(outDir, name)
+			f, err := parser.ParseFile(fset, filename, nil, parser.PackageClauseOnly|parser.ParseComments)
+			if err != nil {
+				continue // not generated by go-co
+			}
+			if by, _ := loader.Generator(f); by != "by "+pkgCoPath {
+				continue
+			}
+
+			// the inverse of the file naming of goGen
+			rel, err := filepath.Rel(base, filename)
+			if err != nil {
+				return nil, err
+			}
+			co := strings.TrimSuffix(filepath.Join(dir, rel), ".go")
+			if strings.HasSuffix(co, "_test") {
+				co = strings.TrimSuffix(co, "_test") + "_" + suffix + "_test.go"
+			} else {
+				co += "_" + suffix + ".go"
+			}
+			if _, err := os.Stat(co); os.IsNotExist(err) {
+				orphans = append(orphans, filename)
+			}
+		}
+	}
+	return
+}
+
+// the same as loader.File.WriteWithComment, but in memory
+func formatWithComment(f *loader.File, comment string) []byte {
+	src := f.Format()
+	if !strings.HasPrefix(src, comment) {
+		src = comment + src
+	}
+	return []byte(src)
+}
+
+// the same as loader.MustNew(dir, loader.WithLoadDepts(), loader.WithLoadTest(), loader.WithSuppressErrors()),
+// except the packages are the dirs of the overlay files, which exist in memory only
+func loadOverlay(dir string, overlay map[string][]byte) (*loader.Loader, error) {
+	seen := map[string]bool{}
+	var patterns []string
+	for filename := range overlay {
+		if d := filepath.Dir(filename); !seen[d] {
+			seen[d] = true
+			patterns = append(patterns, d)
+		}
+	}
+	sort.Strings(patterns)
+
+	fset := token.NewFileSet()
+	cfg := &packages.Config{
+		Fset:    fset,
+		Mode:    overlayLoadMode,
+		Tests:   true,
+		Dir:     dir,
+		Overlay: overlay,
+	}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &loader.Loader{
+		Flags: &loader.Flags{
+			Patterns:  patterns,
+			Test:      true,
+			LoadDepts: true,
+			// skip the main of test generated by go test
+			FileFilter: func(f *loader.File) bool { return f.GenBy != "by 'go test'." },
+		},
+		Cfg:  cfg,
+		FSet: fset,
+		Init: pkgs,
+		All:  map[loader.PackagePath]*packages.Package{},
+		Gen:  map[loader.FileName]loader.GenBy{},
+	}
+	packages.Visit(pkgs, nil, func(p *packages.Package) {
+		l.All[p.PkgPath] = p
+		for _, file := range p.Syntax {
+			if gen, is := loader.Generator(file); is {
+				l.Gen[fset.File(file.Pos()).Name()] = gen
+			}
+		}
+	})
+	return l, nil
+}
+
+const overlayLoadMode = packages.NeedName |
+	packages.NeedFiles |
+	packages.NeedCompiledGoFiles |
+	packages.NeedImports |
+	packages.NeedDeps |
+	packages.NeedTypes |
+	packages.NeedTypesSizes |
+	packages.NeedSyntax |
+	packages.NeedTypesInfo |
+	packages.NeedModule
+
+// ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓ Unified Diff ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓
+
+const diffContext = 3
+
+// unifiedDiff returns the diff of the lines of a and b in the unified format, empty if equal,
+// which is a single hunk from the first to the last different line, in linear time and space,
+// the hand-edited generated file only needs to be spotted, not patched
+func unifiedDiff(from, to, a, b string) string {
+	if a == b {
+		return ""
+	}
+	as, bs := splitLines(a), splitLines(b)
+
+	var prefix, suffix int
+	for prefix < len(as) && prefix < len(bs) && as[prefix] == bs[prefix] {
+		prefix++
+	}
+	for suffix < len(as)-prefix && suffix < len(bs)-prefix &&
+		as[len(as)-1-suffix] == bs[len(bs)-1-suffix] {
+		suffix++
+	}
+
+	start := prefix - diffContext
+	if start < 0 {
+		start = 0
+	}
+	after := minInt(suffix, diffContext)
+	aEnd, bEnd := len(as)-suffix, len(bs)-suffix
+
+	var buf strings.Builder
+	_, _ = fmt.Fprintf(&buf, "--- %s\n+++ %s\n", from, to)
+	_, _ = fmt.Fprintf(&buf, "@@ -%s +%s @@\n",
+		hunkRange(start, aEnd+after-start), hunkRange(start, bEnd+after-start))
+	writeLines(&buf, ' ', as[start:prefix])
+	writeLines(&buf, '-', as[prefix:aEnd])
+	writeLines(&buf, '+', bs[prefix:bEnd])
+	writeLines(&buf, ' ', as[aEnd:aEnd+after])
+	return buf.String()
+}
+
+func writeLines(buf *strings.Builder, kind byte, lines []string) {
+	for _, l := range lines {
+		buf.WriteByte(kind)
+		buf.WriteString(l)
+		if !strings.HasSuffix(l, "\n") {
+			buf.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// the range of n lines after the start lines, the empty range starts at the line before
+func hunkRange(start, n int) string {
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// the lines with the trailing newline
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
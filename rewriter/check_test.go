@@ -0,0 +1,131 @@
+package rewriter
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	in := "../example/tree"
+	out := t.TempDir()
+
+	var buf strings.Builder
+	stale, err := Check(in, &buf, WithOutputDir(out))
+	assertEqual(t, err, nil)
+	assertEqual(t, stale, []string{"tree.go", "tree_test.go"})
+	assertEqual(t, strings.HasPrefix(buf.String(), "--- /dev/null\n+++ b/tree.go\n"), true)
+
+	assertEqual(t, GoGen(in, WithOutputDir(out)), nil)
+	buf.Reset()
+	stale, _ = Check(in, &buf, WithOutputDir(out))
+	assertEqual(t, len(stale), 0)
+	assertEqual(t, buf.String(), "")
+
+	// the generated file edited by hand
+	f := path.Join(out, "tree.go")
+	src, _ := os.ReadFile(f)
+	_ = os.WriteFile(f, []byte(strings.Replace(string(src), "package tree", "package tree\n\nvar x = 1", 1)), 0666)
+	stale, _ = Check(in, &buf, WithOutputDir(out))
+	assertEqual(t, stale, []string{"tree.go"})
+	assertEqual(t, strings.Contains(buf.String(), "\n-var x = 1\n"), true)
+
+	// the generated file whose co file is removed
+	assertEqual(t, GoGen(in, WithOutputDir(out)), nil)
+	src, _ = os.ReadFile(f)
+	_ = os.WriteFile(path.Join(out, "removed.go"), src, 0666)
+	_ = os.WriteFile(path.Join(out, "plain.go"), []byte("package tree\n"), 0666)
+	buf.Reset()
+	stale, _ = Check(in, &buf, WithOutputDir(out))
+	assertEqual(t, stale, []string{"removed.go"})
+	assertEqual(t, strings.HasPrefix(buf.String(), "--- a/removed.go\n+++ /dev/null\n"), true)
+}
+
+func TestCheckPatterns(t *testing.T) {
+	in := "../example"
+	out := t.TempDir()
+	assertEqual(t, GoGen(in, WithOutputDir(out), WithPatterns("./tree")), nil)
+
+	// the generated files unrelated to the co files of tree
+	src, _ := os.ReadFile(path.Join(out, "tree", "tree.go"))
+	for _, f := range []string{"tree/removed.go", "lexer/removed.go", "gen/gen.go"} {
+		_ = os.MkdirAll(path.Join(out, path.Dir(f)), 0777)
+		_ = os.WriteFile(path.Join(out, f), src, 0666)
+	}
+
+	var buf strings.Builder
+	stale, err := Check(in, &buf, WithOutputDir(out), WithPatterns("./tree"))
+	assertEqual(t, err, nil)
+	assertEqual(t, stale, []string{"tree/removed.go"})
+
+	// gen is not generated from the co files, e.g., the output of Compile
+	stale, err = Check(in, &buf, WithOutputDir(out), WithPatterns("./..."))
+	assertEqual(t, err, nil)
+	has := map[string]bool{}
+	for _, f := range stale {
+		has[f] = true
+	}
+	assertEqual(t, has["lexer/removed.go"], true)
+	assertEqual(t, has["gen/gen.go"], false)
+}
+
+func TestUnifiedDiffLarge(t *testing.T) {
+	// no quadratic table, which is 1<<30 cells here
+	a := strings.Repeat("a\n", 1<<15)
+	b := strings.Repeat("b\n", 1<<15)
+	d := unifiedDiff("a", "b", a, b)
+	assertEqual(t, strings.HasPrefix(d, "--- a\n+++ b\n@@ -1,32768 +1,32768 @@\n-a\n"), true)
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(xs ...string) string { return strings.Join(xs, "\n") + "\n" }
+
+	for _, tt := range []struct {
+		a, b   string
+		expect string
+	}{
+		{"", "", ""},
+		{lines("a", "b"), lines("a", "b"), ""},
+		{
+			"",
+			lines("a"),
+			lines("--- a", "+++ b", "@@ -0,0 +1 @@", "+a"),
+		},
+		{
+			lines("1", "2", "3", "4", "5", "6", "7", "8", "9", "10"),
+			lines("1", "2", "3", "4", "x", "6", "7", "8", "9", "10"),
+			lines("--- a", "+++ b",
+				"@@ -2,7 +2,7 @@", " 2", " 3", " 4", "-5", "+x", " 6", " 7", " 8"),
+		},
+		{
+			lines("1", "2", "3"),
+			lines("1", "2"),
+			lines("--- a", "+++ b", "@@ -1,3 +1,2 @@", " 1", " 2", "-3"),
+		},
+		{
+			// a single hunk from the first to the last difference
+			lines("1", "2", "3", "4", "5", "6", "7", "8"),
+			lines("1", "2", "x", "4", "5", "6", "y", "8"),
+			lines("--- a", "+++ b",
+				"@@ -1,8 +1,8 @@", " 1", " 2", "-3", "-4", "-5", "-6", "-7", "+x", "+4", "+5", "+6", "+y", " 8"),
+		},
+		{
+			"a",
+			"b",
+			lines("--- a", "+++ b", "@@ -1 +1 @@", "-a", `\ No newline at end of file`, "+b", `\ No newline at end of file`),
+		},
+	} {
+		if got := unifiedDiff("a", "b", tt.a, tt.b); got != tt.expect {
+			t.Errorf("expect\n%s\ngot\n%s", tt.expect, got)
+		}
+	}
+}
+
+func assertEqual(t *testing.T, got, expect any) {
+	t.Helper()
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expect %v got %v", expect, got)
+	}
+}
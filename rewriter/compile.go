@@ -2,9 +2,12 @@ package rewriter
 
 import (
 	"fmt"
+	"go/ast"
+	"go/build/constraint"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/goghcrow/go-ast-matcher"
@@ -12,18 +15,81 @@ import (
 	"github.com/goghcrow/go-matcher"
 )
 
-const fileComment = `//go:build !%s
+const fileComment = `//go:build %s
 
 // Code generated by github.com/goghcrow/go-co DO NOT EDIT.
 `
 
 type FilePrinter func(filename string, f *loader.File)
 
-func Compile(srcDir, dstDir string, opts ...loader.Option) {
-	srcDir, err := filepath.Abs(srcDir)
-	panicIf(err)
+// the build constraints of the source file are kept, except the build tag of co
+//
+//	//go:build co && go1.23
+//	=>
+//	//go:build !co && go1.23
+func fileCommentOf(f *ast.File, tag string) string {
+	var expr constraint.Expr = &constraint.NotExpr{X: &constraint.TagExpr{Tag: tag}}
+	for _, g := range f.Comments {
+		if g.End() >= f.Package {
+			break
+		}
+		for _, c := range g.List {
+			if !constraint.IsGoBuild(c.Text) {
+				continue
+			}
+			if x, err := constraint.Parse(c.Text); err == nil {
+				if x = withoutTag(x, tag); x != nil {
+					expr = &constraint.AndExpr{X: expr, Y: x}
+				}
+			}
+		}
+	}
+	return fmt.Sprintf(fileComment, expr.String())
+}
 
-	dstDir = mustMkDir(dstDir)
+// return nil if always true after removing tag
+func withoutTag(x constraint.Expr, tag string) constraint.Expr {
+	switch x := x.(type) {
+	case *constraint.TagExpr:
+		if x.Tag == tag {
+			return nil
+		}
+	case *constraint.NotExpr:
+		if t, ok := x.X.(*constraint.TagExpr); ok && t.Tag == tag {
+			return nil
+		}
+	case *constraint.AndExpr:
+		l, r := withoutTag(x.X, tag), withoutTag(x.Y, tag)
+		if l == nil {
+			return r
+		}
+		if r == nil {
+			return l
+		}
+		return &constraint.AndExpr{X: l, Y: r}
+	case *constraint.OrExpr:
+		l, r := withoutTag(x.X, tag), withoutTag(x.Y, tag)
+		if l == nil || r == nil {
+			return nil
+		}
+		return &constraint.OrExpr{X: l, Y: r}
+	}
+	return x
+}
+
+// Compile rewrites the packages in srcDir to dstDir, the error is Diagnostics
+// if the co source is not supported, and nothing written to dstDir
+func Compile(srcDir, dstDir string, opts ...Option) error {
+	opt := mkOption(opts)
+
+	srcDir, err := filepath.Abs(srcDir)
+	if err != nil {
+		return err
+	}
+	dstDir, err = filepath.Abs(dstDir)
+	if err != nil {
+		return err
+	}
 
 	tmpOutputDir := mustMkDir(dstDir + "_tmp")
 	// tmpOutputDir, err = os.MkdirTemp("", "co_")
@@ -35,27 +101,62 @@ func Compile(srcDir, dstDir string, opts ...loader.Option) {
 
 	resetLog()
 	log.SetPrefix("[rewrite] ")
-	r := mkRewriter(astmatcher.New(
-		loader.MustNew(srcDir, append(opts, loader.WithLoadDepts())...),
-		matcher.New(),
-	))
+	// the co files are loaded with the build tag, and the generated files without
+	l, err := loader.New(srcDir, append(opt.loaderOpts, loader.WithLoadDepts(), loader.WithBuildTag(opt.buildTag))...)
+	if err != nil {
+		return err
+	}
+	if ds := typeErrors(l, func(string) bool { return true }); opt.typeCheck && len(ds) > 0 {
+		return ds
+	}
+	r := mkRewriter(astmatcher.New(l, matcher.New()), opt.buildTag)
+	r.machine = opt.machine
+	r.lines = opt.lines
 
-	comment := fmt.Sprintf(fileComment, defaultBuildTag)
+	var emitErr error
 	r.rewriteAllFiles(func(filename string, f *loader.File) {
+		src := formatWithComment(f, r.fileComment)
+		if opt.lines {
+			src = formatWithLines(f, r.fileComment, strings.ReplaceAll(filename, srcDir, dstDir), r.stmtPos)
+		}
 		filename = strings.ReplaceAll(filename, srcDir, tmpOutputDir)
-		f.WriteWithComment(filename, comment)
+		if err := writeFile(filename, src); err != nil && emitErr == nil {
+			emitErr = err
+		}
 	})
+	if len(r.diags) > 0 {
+		r.diags.sort()
+		return r.diags
+	}
+	if emitErr != nil {
+		return emitErr
+	}
 
 	// type info broken after rewriting, so reload to optimize
 	log.SetPrefix("[optimize] ")
-	o := mkOptimizer(astmatcher.New(
-		loader.MustNew(tmpOutputDir, append(opts, loader.WithLoadDepts(), loader.WithSuppressErrors())...),
-		matcher.New(),
-	))
+	l, err = loader.New(tmpOutputDir, append(opt.loaderOpts, loader.WithLoadDepts(), loader.WithSuppressErrors())...)
+	if err != nil {
+		return err
+	}
+	o := mkOptimizer(astmatcher.New(l, matcher.New()))
 	o.optimizeAllFiles(func(filename string, f *loader.File) {
 		filename = strings.ReplaceAll(filename, tmpOutputDir, dstDir)
-		f.WriteWithComment(filename, comment)
+		src := formatWithComment(f, fileCommentOf(f.File, opt.buildTag))
+		if opt.lines {
+			src = trimLineDirectives(src)
+		}
+		if err := writeFile(filename, src); err != nil && emitErr == nil {
+			emitErr = err
+		}
 	})
+	return emitErr
+}
+
+func writeFile(filename string, src []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, src, 0666)
 }
 
 type (
@@ -63,36 +164,92 @@ type (
 	option struct {
 		fileSuffix string
 		buildTag   string
+		machine    bool
+		lines      bool
+		typeCheck  bool
+		patterns   []string // for GoGen
+		outputDir  string   // for GoGen
+		loaderOpts []loader.Option
 	}
 )
 
 func WithFileSuffix(s string) Option { return func(opt *option) { opt.fileSuffix = s } }
 func WithBuildTag(s string) Option   { return func(opt *option) { opt.buildTag = s } }
 
+// WithStateMachine compiles the yield func to the state machine driven by seq.Machine,
+// instead of the monadic combinators, the yield func not supported falls back, e.g., goto
+func WithStateMachine() Option { return func(opt *option) { opt.machine = true } }
+
+// WithLineDirectives emits the //line directives of the co files in the generated files,
+// so the panics, debuggers and go vet refer to the positions of the co files
+func WithLineDirectives() Option { return func(opt *option) { opt.lines = true } }
+
+// WithTypeCheck returns the type errors of the packages of the co files as Diagnostics of CodeTypeError,
+// instead of rewriting the packages not well typed
+func WithTypeCheck() Option { return func(opt *option) { opt.typeCheck = true } }
+
+// WithPatterns specifies the packages of GoGen by the go list patterns relative to dir,
+// e.g., "./...", which is the default
+func WithPatterns(patterns ...string) Option {
+	return func(opt *option) { opt.patterns = patterns }
+}
+
+// WithOutputDir writes the generated files of GoGen under dir, keeping the layout relative to
+// the dir of GoGen, instead of beside the co files
+func WithOutputDir(dir string) Option { return func(opt *option) { opt.outputDir = dir } }
+
+// WithLoaderOptions passes the options to the loader, e.g., loader.WithLoadTest()
+func WithLoaderOptions(opts ...loader.Option) Option {
+	return func(opt *option) { opt.loaderOpts = append(opt.loaderOpts, opts...) }
+}
+
 const (
 	defaultFileSuffix = "co"
 	defaultBuildTag   = "co"
 )
 
-func GoGen(dir string, opts ...Option) {
+func mkOption(opts []Option) *option {
 	opt := &option{
 		fileSuffix: defaultFileSuffix,
 		buildTag:   defaultBuildTag,
+		patterns:   []string{loader.PatternAll},
 	}
 	for _, o := range opts {
 		o(opt)
 	}
+	return opt
+}
 
-	tmpOutputDir := mustMkDir(dir + "_tmp")
-	if !runningWithGoTest {
-		//goland:noinspection GoUnhandledErrorResult
-		defer os.RemoveAll(tmpOutputDir)
+// GoGen rewrites the co files of the packages in dir, the generated files are written
+// beside the co files, or under the output dir by WithOutputDir,
+// the error is Diagnostics if the co files are not supported, and nothing written
+func GoGen(dir string, opts ...Option) error {
+	_, err := goGen(dir, mkOption(opts), writeFile)
+	return err
+}
+
+// goGen runs the pipeline of GoGen, the generated files are passed to emit,
+// the rewritten files are kept in memory, and reloaded by the overlay to optimize,
+// outDirs are the dirs of the generated files of the loaded packages with co files
+func goGen(dir string, opt *option, emit func(filename string, src []byte) error) (outDirs []string, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	dstDir := dir
+	if opt.outputDir != "" {
+		if dstDir, err = filepath.Abs(opt.outputDir); err != nil {
+			return nil, err
+		}
 	}
 
+	// inside dir to be loaded in the same module, never written
+	tmpOutputDir := filepath.Join(dir, "_co_tmp")
+	overlay := map[string][]byte{}
+
 	var (
 		endsWith       = strings.HasSuffix
 		replace        = strings.ReplaceAll
-		comment        = fmt.Sprintf(fileComment, opt.buildTag)
 		srcFileSuffix  = fmt.Sprintf("_%s.go", opt.fileSuffix)
 		testFileSuffix = fmt.Sprintf("_%s_test.go", opt.fileSuffix)
 		isCoFile       = func(filename string) bool {
@@ -102,35 +259,81 @@ func GoGen(dir string, opts ...Option) {
 
 	resetLog()
 	log.SetPrefix("[rewrite] ")
-	r := mkRewriter(astmatcher.New(
-		loader.MustNew(dir,
-			loader.WithLoadDepts(),
-			loader.WithLoadTest(),
-			loader.WithBuildTag(opt.buildTag),
-			loader.WithFileFilter(func(f *loader.File) bool { return isCoFile(f.Filename) }),
-		),
-		matcher.New(),
-	))
+	l, err := loader.New(dir, append(opt.loaderOpts,
+		loader.WithPatterns(opt.patterns...),
+		loader.WithLoadDepts(),
+		loader.WithLoadTest(),
+		loader.WithBuildTag(opt.buildTag),
+		loader.WithFileFilter(func(f *loader.File) bool { return isCoFile(f.Filename) }),
+	)...)
+	if err != nil {
+		return nil, err
+	}
+	if ds := typeErrors(l, isCoFile); opt.typeCheck && len(ds) > 0 {
+		return nil, ds
+	}
+	outDirs = coOutDirs(l, isCoFile, dir, dstDir)
+	r := mkRewriter(astmatcher.New(l, matcher.New()), opt.buildTag)
+	r.machine = opt.machine
+	r.lines = opt.lines
 	r.rewriteAllFiles(func(filename string, f *loader.File) {
 		filename = replace(filename, srcFileSuffix, ".go")
 		filename = replace(filename, testFileSuffix, "_test.go")
-		filename = replace(filename, dir, tmpOutputDir)
-		f.WriteWithComment(filename, comment)
+		if opt.lines {
+			overlay[replace(filename, dir, tmpOutputDir)] = formatWithLines(f, r.fileComment, replace(filename, dir, dstDir), r.stmtPos)
+			return
+		}
+		overlay[replace(filename, dir, tmpOutputDir)] = formatWithComment(f, r.fileComment)
 	})
+	if len(r.diags) > 0 {
+		r.diags.sort()
+		return outDirs, r.diags
+	}
+	if len(overlay) == 0 {
+		return outDirs, nil
+	}
 
 	log.SetPrefix("[optimize] ")
-	o := mkOptimizer(astmatcher.New(
-		loader.MustNew(tmpOutputDir,
-			loader.WithLoadDepts(),
-			loader.WithLoadTest(),
-			loader.WithSuppressErrors(),
-		),
-		matcher.New(),
-	))
+	l, err = loadOverlay(dir, overlay)
+	if err != nil {
+		return outDirs, err
+	}
+	o := mkOptimizer(astmatcher.New(l, matcher.New()))
+	emitted := map[string]bool{}
+	var emitErr error
 	o.optimizeAllFiles(func(filename string, f *loader.File) {
-		filename = strings.ReplaceAll(filename, tmpOutputDir, dir)
-		f.WriteWithComment(filename, comment)
+		// the file of package is visited again in the test variant
+		if emitted[filename] || emitErr != nil {
+			return
+		}
+		emitted[filename] = true
+		filename = strings.ReplaceAll(filename, tmpOutputDir, dstDir)
+		src := formatWithComment(f, fileCommentOf(f.File, opt.buildTag))
+		if opt.lines {
+			src = trimLineDirectives(src)
+		}
+		emitErr = emit(filename, src)
 	})
+	return outDirs, emitErr
+}
+
+// the dirs of the co files of the loaded packages, mapped from dir to dstDir
+func coOutDirs(l *loader.Loader, isCoFile func(filename string) bool, dir, dstDir string) (dirs []string) {
+	seen := map[string]bool{}
+	for _, p := range l.Init {
+		for _, filename := range p.GoFiles {
+			if !isCoFile(filename) {
+				continue
+			}
+			d := filepath.Dir(strings.Replace(filename, dir, dstDir, 1))
+			if !seen[d] {
+				seen[d] = true
+				dirs = append(dirs, d)
+			}
+		}
+	}
+	sort.Strings(dirs)
+	return
 }
 
 func resetLog() {
@@ -1,40 +1,93 @@
 package rewriter
 
 const (
-	cstIterVar  = "ɪʇ" // it۰
+	cstIterVar  = "ɪʇ"  // it۰
+	cstCloseVar = "ɪʇᶜ" // closable it۰
 	cstMoveNext = "MoveNext"
 	cstCurrent  = "Current"
 
-	cstYieldFromRangeVar = "ʌ" // v۰
+	cstDeferVar = "ɗ" // d۰
+	cstCaseVar  = "ç" // c۰
+	cstRecvVar  = "ʀ" // r۰
+	cstGotoVar  = "ɠ" // g۰
 
+	cstMachineVar   = "ʍ" // m۰
+	cstMachineLabel = "ʟ" // l۰
+	cstRenamed      = "ʹ" // suffix of the renamed hoisted var
+
+	cstLineMarker = "ʟɪɴᴇ" // stmt replaced by the //line directive after printing
+
+	cstPair    = "Pair"
 	cstPairKey = "Key"
 	cstPairVal = "Val"
 
-	cstIterator       = "Iterator"
-	cstNewStringIter  = "NewStringIter"
-	cstNewIntegerIter = "NewIntegerIter"
-	cstNewSliceIter   = "NewSliceIter"
-	cstNewMapIter     = "NewMapIter"
-	cstNewChanIter    = "NewChanIter"
+	cstIterator         = "Iterator"
+	cstResultIterator   = "ResultIterator"
+	cstClosableIterator = "ClosableIterator"
+	cstGenerator        = "Generator"
+	cstNewStringIter    = "NewStringIter"
+	cstNewIntegerIter   = "NewIntegerIter"
+	cstNewSliceIter     = "NewSliceIter"
+	cstNewMapIter       = "NewMapIter"
+	cstNewChanIter      = "NewChanIter"
+	cstNewFunc0Iter     = "NewFunc0Iter"
+	cstNewFuncIter      = "NewFuncIter"
+	cstNewFunc2Iter     = "NewFunc2Iter"
 
 	cstSeq      = "Seq"
 	cstStart    = "Start"
+	cstStartSeq = "StartSeq"
+	cstStartRet = "StartResult"
+	cstStartGen = "StartGenerator"
 	cstNormal   = "Normal"
 	cstReturn   = "Return"
+	cstReturnV  = "ReturnValue"
+	cstReturnE  = "ReturnError"
 	cstBreak    = "Break"
 	cstContinue = "Continue"
 	cstDelay    = "Delay"
 	cstBind     = "Bind"
+	cstBindRecv = "BindRecv"
+	cstBindFrom = "BindFrom"
+	cstSend     = "Send"
+	cstClose    = "Close"
+	cstCatch    = "Catch"
+	cstDefer    = "Defer"
 	cstCombine  = "Combine"
 	cstFor      = "For"
 	cstLoop     = "Loop"
 	cstWhile    = "While"
+
+	cstSelect        = "Select"
+	cstSwitch        = "Switch"
+	cstLabeled       = "Labeled"
+	cstLabeledFor    = "LabeledFor"
+	cstBreakLabel    = "BreakLabel"
+	cstContinueLabel = "ContinueLabel"
+	cstDispatch      = "Dispatch"
+	cstGoto          = "Goto"
+
+	cstStateMachine = "StateMachine"
+	cstMachine      = "Machine"
+	cstMachineState = "State"
+	cstMachineRecv  = "Recv"
+	cstReceived     = "Received"
+	cstMachineYield = "Yield"
+	cstMachineFrom  = "YieldFrom"
+	cstMachineDefer = "Defer"
 )
 
 const (
 	cstAPIReturnType = "Iter"
+	cstAPIIter2      = "Iter2"
+	cstAPIErrIter    = "ErrIter"
+	cstAPIGenerator  = "Generator"
+	cstAPICoroutine  = "Coroutine"
 	cstAPIYield      = "Yield"
+	cstAPIYieldRecv  = "YieldRecv"
+	cstAPIYield2     = "Yield2"
 	cstAPIYieldFrom  = "YieldFrom"
+	cstAPIReturn     = "Return"
 )
 
 const (
@@ -46,7 +99,18 @@ const (
 	pkgCoPath     = "github.com/goghcrow/go-co"
 	pkgSeqPath    = "github.com/goghcrow/go-co/seq"
 
+	// iter.Seq[V] since go1.23
+	pkgIterPath = "iter"
+	cstStdSeq   = "Seq"
+
 	qualifiedIter      = pkgCoPath + "." + cstAPIReturnType
+	qualifiedIter2     = pkgCoPath + "." + cstAPIIter2
+	qualifiedErrIter   = pkgCoPath + "." + cstAPIErrIter
+	qualifiedGenerator = pkgCoPath + "." + cstAPIGenerator
+	qualifiedCoroutine = pkgCoPath + "." + cstAPICoroutine
 	qualifiedYield     = pkgCoPath + "." + cstAPIYield
+	qualifiedYieldRecv = pkgCoPath + "." + cstAPIYieldRecv
+	qualifiedYield2    = pkgCoPath + "." + cstAPIYield2
 	qualifiedYieldFrom = pkgCoPath + "." + cstAPIYieldFrom
+	qualifiedReturn    = pkgCoPath + "." + cstAPIReturn
 )
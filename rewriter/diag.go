@@ -0,0 +1,152 @@
+package rewriter
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goghcrow/go-loader"
+)
+
+// Code identifies the kind of the diagnostic, which is stable for the tools to match
+type Code string
+
+const (
+	CodeYieldFuncSignature Code = "invalid yield func signature"
+	CodeYieldExpr          Code = "unsupported yield expr"
+	CodeYieldTypeMismatch  Code = "yield type mismatch"
+	CodeRangeFunc          Code = "invalid range func"
+	CodeIterChan           Code = "iterator used as chan"
+	CodeBreakLabel         Code = "invalid break label"
+	CodeReturn             Code = "invalid return"
+	CodeUnsupported        Code = "unsupported stmt"
+	CodeTypeError          Code = "type error"
+	CodeInternal           Code = "internal error"
+)
+
+// Diagnostic is the error of the co source reported by the rewriter
+type Diagnostic struct {
+	Pos  token.Position
+	Code Code
+	Msg  string
+
+	pos token.Pos // for the analyzer
+}
+
+func (d *Diagnostic) Error() string {
+	if d.Pos.Filename == "" && !d.Pos.IsValid() {
+		return d.Msg // e.g., the package not found
+	}
+	return fmt.Sprintf("%s: %s", d.Pos, d.Msg)
+}
+
+// Diagnostics is the error of all the diagnostics of the rewriting, sorted by position,
+// the yield func is skipped after the first diagnostic of it, and the others are checked still
+type Diagnostics []*Diagnostic
+
+func (ds Diagnostics) Error() string {
+	xs := make([]string, len(ds))
+	for i, d := range ds {
+		xs[i] = d.Error()
+	}
+	return strings.Join(xs, "\n")
+}
+
+func (ds Diagnostics) sort() {
+	sort.SliceStable(ds, func(i, j int) bool {
+		a, b := ds[i].Pos, ds[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+func mkDiagnostic(pkg loader.Pkg, pos any, code Code, format string, a ...any) *Diagnostic {
+	d := &Diagnostic{Code: code, Msg: fmt.Sprintf(format, a...)}
+	switch pos := pos.(type) {
+	case ast.Node:
+		if !isNil(pos) {
+			d.pos = pos.Pos()
+		}
+	case token.Pos:
+		d.pos = pos
+	}
+	if d.pos.IsValid() {
+		d.Pos = pkg.Fset.Position(d.pos)
+	}
+	return d
+}
+
+// typeErrors returns the errors of the loaded packages containing the co files, e.g., the type errors,
+// or not found, which are reported once for the package and the test variant,
+// the errors without position are skipped if any with, which are the output of the compiler
+func typeErrors(l *loader.Loader, isCoFile func(filename string) bool) (ds Diagnostics) {
+	seen := map[string]bool{}
+	for _, p := range l.Init {
+		co := len(p.GoFiles) == 0
+		for _, filename := range p.GoFiles {
+			co = co || isCoFile(filename)
+		}
+		if !co {
+			continue
+		}
+		positioned := false
+		for _, err := range p.Errors {
+			positioned = positioned || err.Pos != ""
+		}
+		for _, err := range p.Errors {
+			if seen[err.Error()] || positioned && err.Pos == "" {
+				continue
+			}
+			seen[err.Error()] = true
+			ds = append(ds, &Diagnostic{Pos: parsePosition(err.Pos), Code: CodeTypeError, Msg: err.Msg})
+		}
+	}
+	ds.sort()
+	return
+}
+
+// file:line:col, file:line or file
+func parsePosition(s string) (pos token.Position) {
+	xs := strings.Split(s, ":")
+	var nums []int
+	for len(xs) > 1 && len(nums) < 2 {
+		n, err := strconv.Atoi(xs[len(xs)-1])
+		if err != nil {
+			break
+		}
+		nums = append([]int{n}, nums...)
+		xs = xs[:len(xs)-1]
+	}
+	pos.Filename = strings.Join(xs, ":")
+	if len(nums) > 0 {
+		pos.Line = nums[0]
+	}
+	if len(nums) > 1 {
+		pos.Column = nums[1]
+	}
+	return
+}
+
+// catch reports the diagnostic panicked by assert in fn, false if reported,
+// the other panics are raised again
+func (r *rewriter) catch(fn func()) (ok bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			d, is := p.(*Diagnostic)
+			if !is {
+				panic(p)
+			}
+			r.diags = append(r.diags, d)
+		}
+	}()
+	fn()
+	return true
+}
@@ -0,0 +1,40 @@
+package rewriter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	out := t.TempDir()
+	err := GoGen("testdata/diag", WithOutputDir(out))
+
+	ds, ok := err.(Diagnostics)
+	if !ok {
+		t.Fatalf("expect Diagnostics got %v", err)
+	}
+	var got []string
+	for _, d := range ds {
+		got = append(got, fmt.Sprintf("%s:%d:%d %s", filepath.Base(d.Pos.Filename), d.Pos.Line, d.Pos.Column, d.Code))
+	}
+	assertEqual(t, got, []string{
+		"a_co.go:10:2 invalid yield func signature",
+		"a_co.go:15:7 yield type mismatch",
+		"a_co.go:20:2 unsupported yield expr",
+		"b_co.go:14:4 invalid break label",
+		"c_co.go:11:3 invalid yield func signature",
+		"c_co.go:18:3 invalid yield func signature",
+		"c_co.go:30:7 iterator used as chan",
+		"c_co.go:31:13 iterator used as chan",
+		"c_co.go:35:6 iterator used as chan",
+		"d_co.go:11:21 invalid return",
+		"d_co.go:16:9 invalid return",
+		"d_co.go:20:9 invalid return",
+	})
+
+	// nothing written if any diagnostic
+	xs, _ := os.ReadDir(out)
+	assertEqual(t, len(xs), 0)
+}
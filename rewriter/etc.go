@@ -74,6 +74,13 @@ func (factor) Call(fun ast.Expr, args ...ast.Expr) *ast.CallExpr {
 	}
 }
 
+func (factor) Unary(op token.Token, x ast.Expr) *ast.UnaryExpr {
+	return &ast.UnaryExpr{
+		Op: op,
+		X:  x,
+	}
+}
+
 func (factor) Assign(tok token.Token, lhs, rhs ast.Expr) *ast.AssignStmt {
 	return &ast.AssignStmt{
 		Lhs: []ast.Expr{lhs},
@@ -94,6 +101,14 @@ func (factor) Define(lhs, rhs ast.Expr) *ast.AssignStmt {
 	return X.Assign(token.DEFINE, lhs, rhs)
 }
 
+func (factor) Defines(lhs, rhs []ast.Expr) *ast.AssignStmt {
+	return &ast.AssignStmt{
+		Lhs: lhs,
+		Tok: token.DEFINE,
+		Rhs: rhs,
+	}
+}
+
 func (factor) IgnoreExpr(expr ast.Expr) *ast.AssignStmt {
 	return X.Assign(token.ASSIGN, X.Ident("_"), expr)
 }
@@ -125,12 +140,27 @@ func (factor) Case(list []ast.Expr, body []ast.Stmt) *ast.CaseClause {
 	}
 }
 
+func (factor) CommClause(comm ast.Stmt, body []ast.Stmt) *ast.CommClause {
+	return &ast.CommClause{
+		Comm: comm,
+		Body: body,
+	}
+}
+
+func (factor) SelectStmt(body *ast.BlockStmt) *ast.SelectStmt {
+	return &ast.SelectStmt{
+		Body: body,
+	}
+}
+
 func (factor) Switch(
 	init ast.Stmt,
 	x ast.Node,
 	body *ast.BlockStmt,
 ) ast.Stmt {
 	switch x := x.(type) {
+	case nil: // tagless switch
+		return X.SwitchStmt(init, nil, body)
 	case ast.Expr:
 		return X.SwitchStmt(init, x, body)
 	case ast.Stmt:
@@ -237,6 +267,14 @@ func isUnderline(expr ast.Expr) bool {
 	return ok && ident.Name == "_"
 }
 
+func isFallthrough(stmts []ast.Stmt) bool {
+	if len(stmts) == 0 {
+		return false
+	}
+	br, ok := stmts[len(stmts)-1].(*ast.BranchStmt)
+	return ok && br.Tok == token.FALLTHROUGH
+}
+
 func isDefineStmt(stmt ast.Stmt) bool {
 	assign, ok := stmt.(*ast.AssignStmt)
 	return ok && assign.Tok == token.DEFINE
@@ -0,0 +1,446 @@
+package rewriter
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+// goto is lowered into a dispatch loop,
+// when the stmt list containing the target labels contains yield.
+// the stmts are split into blocks by the target labels,
+// each block is rewritten into a seq.Delay.
+//
+//	$head
+//	L1:
+//		$stmts1
+//		goto L2
+//	L2:
+//		$stmts2
+//		goto L1
+//
+// WOULD BE REWRITTEN TO
+//
+//	$head
+//	ɠL1 := 0
+//	return Dispatch("ɠL1", &ɠL1,
+//		Delay(func() Seq[T] {
+//			$stmts1
+//			return Goto("ɠL1", &ɠL1, 1)
+//		}),
+//		Delay(func() Seq[T] {
+//			$stmts2
+//			return Goto("ɠL1", &ɠL1, 0)
+//		}),
+//	)
+//
+// the stmts in $head after the first one containing goto are dispatched too,
+// they are the first block without label.
+//
+// goto stmt is rewritten in pass3, only the goto targeting the lowered label
+// which is not kept natively is rewritten.
+type gotoTarget struct {
+	pc  string // name of the state var, also used as the label of Dispatch
+	idx int    // index of the target block
+}
+
+func (r *yieldRewriter) collectGotoLabels(body *ast.BlockStmt) map[string]bool {
+	labels := map[string]bool{}
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false // goto can't jump across func
+		case *ast.BranchStmt:
+			if n.Tok == token.GOTO {
+				labels[n.Label.Name] = true
+			}
+		}
+		return true
+	})
+	return labels
+}
+
+// the labeled stmt targeted by goto,
+// stmt maybe the block wrapping extracted init and labeled stmt, e.g.,
+//
+//	{ $init; L: for ; ; { ... } }
+func (r *yieldRewriter) gotoLabelOf(stmt ast.Stmt) *ast.LabeledStmt {
+	if b, ok := stmt.(*ast.BlockStmt); ok && r.initBlocks[b] {
+		stmt = b.List[len(b.List)-1]
+	}
+	if l, ok := stmt.(*ast.LabeledStmt); ok && r.gotoLabels[l.Label.Name] {
+		if _, lowered := r.gotoTargets[l.Label.Name]; !lowered {
+			return l
+		}
+	}
+	return nil
+}
+
+func (r *yieldRewriter) containsGotoTo(stmt ast.Stmt, labels map[string]bool) (found bool) {
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.BranchStmt:
+			if n.Tok == token.GOTO {
+				found = labels[n.Label.Name]
+			}
+		}
+		return !found
+	})
+	return
+}
+
+// return the stmts, which the dispatched part is replaced with
+//
+//	ɠL := 0
+//	return Dispatch(...)
+//
+// or the original stmts if no lowering required
+func (r *yieldRewriter) lowerGotoIfNecessary(stmts []ast.Stmt) []ast.Stmt {
+	at := -1
+	labels := map[string]bool{}
+	for i, stmt := range stmts {
+		if l := r.gotoLabelOf(stmt); l != nil {
+			if at < 0 {
+				at = i
+			}
+			labels[l.Label.Name] = true
+		}
+	}
+	if at < 0 {
+		return stmts
+	}
+
+	start := at
+	for i := 0; i < at; i++ {
+		if r.containsGotoTo(stmts[i], labels) {
+			start = i
+			break
+		}
+	}
+
+	// goto works natively in trival stmts
+	if r.mustNoYield(X.Block(stmts[start:]...)) {
+		return stmts
+	}
+
+	var (
+		segs [][]ast.Stmt
+		pc   string
+	)
+	if start < at {
+		segs = append(segs, stmts[start:at])
+	}
+	for i := at; i < len(stmts); i++ {
+		l := r.gotoLabelOf(stmts[i])
+		if l == nil {
+			segs[len(segs)-1] = append(segs[len(segs)-1], stmts[i])
+			continue
+		}
+		if pc == "" {
+			pc = cstGotoVar + l.Label.Name
+		}
+		r.gotoTargets[l.Label.Name] = gotoTarget{pc: pc, idx: len(segs)}
+		segs = append(segs, []ast.Stmt{r.stripGotoLabel(stmts[i], l)})
+	}
+	hoisted := r.hoistGotoScope(segs)
+
+	blocks := make([]ast.Expr, len(segs))
+	for i, seg := range segs {
+		body := r.rewriteBlockStmt(X.Block(seg...), kindDelay)
+		blocks[i] = r.CallDelay(body.block)
+	}
+
+	dispatch := X.Return(r.CallDispatch(pc, blocks))
+	r.dispatches[dispatch] = true
+
+	head := append(stmts[:start:start], hoisted...)
+	return append(head, X.Define(X.Ident(pc), X.Ident("0")), dispatch)
+}
+
+// label only targeted by goto is removed,
+// otherwise "label defined and not used"
+func (r *yieldRewriter) stripGotoLabel(stmt ast.Stmt, l *ast.LabeledStmt) ast.Stmt {
+	used := false
+	ast.Inspect(l.Stmt, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.BranchStmt:
+			if n.Tok != token.GOTO && n.Label != nil && n.Label.Name == l.Label.Name {
+				used = true
+			}
+		}
+		return !used
+	})
+	if used {
+		return stmt
+	}
+	if b, ok := stmt.(*ast.BlockStmt); ok && r.initBlocks[b] {
+		b.List[len(b.List)-1] = l.Stmt
+		return b
+	}
+	return l.Stmt
+}
+
+// every block is rewritten into a separate callback,
+// so the var declared in a block and referred by the following blocks is hoisted before the dispatch,
+// the declaration is replaced with the assignment, e.g.,
+//
+//	L1:
+//		x := 1
+//	L2:
+//		Yield(x)
+//
+// WOULD BE REWRITTEN TO
+//
+//	var x int
+//	ɠL1 := 0
+//	return Dispatch("ɠL1", &ɠL1,
+//		Delay(func() Seq[T] { x = 1; ... }),
+//		Delay(func() Seq[T] { ... Yield(x) ... }),
+//	)
+//
+// the const and type declarations are moved before the dispatch as is,
+// the hoisted var is renamed if it shadows the name referenced in the blocks
+func (r *yieldRewriter) hoistGotoScope(segs [][]ast.Stmt) (hoisted []ast.Stmt) {
+	info := r.pkg.TypeInfo()
+	var (
+		specs []ast.Spec
+		objs  []types.Object
+	)
+	for i, seg := range segs {
+		used := map[types.Object]bool{}
+		for _, following := range segs[i+1:] {
+			for _, stmt := range following {
+				ast.Inspect(stmt, func(n ast.Node) bool {
+					if id, ok := n.(*ast.Ident); ok && info.Uses[id] != nil {
+						used[info.Uses[id]] = true
+					}
+					return true
+				})
+			}
+		}
+		crossing := func(ids ...*ast.Ident) bool {
+			for _, id := range ids {
+				if obj := info.Defs[id]; obj != nil && used[obj] {
+					return true
+				}
+			}
+			return false
+		}
+		hoist := func(ids ...*ast.Ident) {
+			for _, id := range ids {
+				if obj := info.Defs[id]; obj != nil && !isUnderline(id) {
+					typ := r.hoistedTypeExpr(id, obj.Type())
+					specs = append(specs, &ast.ValueSpec{Names: []*ast.Ident{X.Ident(id.Name)}, Type: typ})
+					objs = append(objs, obj)
+				}
+			}
+		}
+
+		var stmts []ast.Stmt
+		for _, stmt := range seg {
+			switch s := stmt.(type) {
+			case *ast.AssignStmt:
+				if ids := definedIdents(s); crossing(ids...) {
+					hoist(ids...)
+					stmts = append(stmts, &ast.AssignStmt{Lhs: s.Lhs, TokPos: s.TokPos, Tok: token.ASSIGN, Rhs: s.Rhs})
+					continue
+				}
+			case *ast.DeclStmt:
+				decl := s.Decl.(*ast.GenDecl)
+				if decl.Tok != token.VAR {
+					// moved as a whole, the const specs may depend on iota and the previous one
+					for _, spec := range decl.Specs {
+						if crossing(specIdents(spec)...) {
+							hoisted = append(hoisted, s)
+							break
+						}
+					}
+					if len(hoisted) == 0 || hoisted[len(hoisted)-1] != s {
+						stmts = append(stmts, s)
+					}
+					continue
+				}
+				var kept []ast.Spec
+				for _, it := range decl.Specs {
+					spec := it.(*ast.ValueSpec)
+					if crossing(spec.Names...) {
+						hoist(spec.Names...)
+						stmts = append(stmts, r.assignValueSpec(spec)...)
+					} else {
+						kept = append(kept, spec)
+					}
+				}
+				if len(kept) == len(decl.Specs) {
+					stmts = append(stmts, s)
+				} else if len(kept) > 0 {
+					stmts = append(stmts, &ast.DeclStmt{Decl: &ast.GenDecl{TokPos: decl.TokPos, Tok: token.VAR, Specs: kept}})
+				}
+				continue
+			}
+			stmts = append(stmts, stmt)
+		}
+		segs[i] = stmts
+	}
+	if len(specs) > 0 {
+		r.renameGotoHoisted(segs, specs, objs)
+		decl := &ast.DeclStmt{Decl: &ast.GenDecl{Tok: token.VAR, Specs: specs}}
+		hoisted = append([]ast.Stmt{decl}, hoisted...)
+	}
+	return
+}
+
+// the same as machine.renameHoisted, e.g., the outer x referenced before the inner x declared
+//
+//	x := 1
+//	L:
+//		Yield(x)
+//		x := 2
+//	M:
+//		Yield(x)
+//
+// WOULD BE REWRITTEN TO
+//
+//	var xʹ1 int
+//	return Dispatch(...
+//		Delay(func() Seq[T] { ... Yield(x) ... xʹ1 = 2 ... }),
+//		Delay(func() Seq[T] { ... Yield(xʹ1) ... }),
+//	)
+func (r *yieldRewriter) renameGotoHoisted(segs [][]ast.Stmt, specs []ast.Spec, objs []types.Object) {
+	var (
+		refs   = map[string]map[any]bool{} // name => keys
+		hoist  = map[any]bool{}
+		rename = map[types.Object]string{}
+	)
+	for _, seg := range segs {
+		for _, stmt := range seg {
+			ast.Inspect(stmt, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.SelectorExpr:
+					ast.Inspect(n.X, func(n ast.Node) bool {
+						if id, ok := n.(*ast.Ident); ok {
+							addRef(refs, id.Name, keyOf(r.pkg.ObjectOf(id), id))
+						}
+						return true
+					})
+					return false
+				case *ast.Ident:
+					addRef(refs, n.Name, keyOf(r.pkg.ObjectOf(n), n))
+				}
+				return true
+			})
+		}
+	}
+	for _, obj := range objs {
+		hoist[obj] = true
+	}
+
+	claimed := map[string]bool{}
+	for i, obj := range objs {
+		id := specs[i].(*ast.ValueSpec).Names[0]
+		conflict := claimed[id.Name]
+		for k := range refs[id.Name] {
+			conflict = conflict || k != obj && !hoist[k]
+		}
+		if conflict {
+			name := id.Name
+			for n := 1; claimed[name] || len(refs[name]) > 0; n++ {
+				name = id.Name + cstRenamed + strconv.Itoa(n)
+			}
+			rename[obj] = name
+			id.Name = name
+		}
+		claimed[id.Name] = true
+	}
+	if len(rename) == 0 {
+		return
+	}
+
+	for _, seg := range segs {
+		for _, stmt := range seg {
+			ast.Inspect(stmt, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok {
+					if name, ok := rename[r.pkg.ObjectOf(id)]; ok {
+						id.Name = name
+					}
+				}
+				return true
+			})
+		}
+	}
+}
+
+// var $a, $b T = $x, $y
+// =>
+// $a, $b = $x, $y
+//
+// or the zero value if no value, the block may be executed more than once by goto
+func (r *yieldRewriter) assignValueSpec(spec *ast.ValueSpec) (stmts []ast.Stmt) {
+	if spec.Values != nil {
+		lhs := make([]ast.Expr, len(spec.Names))
+		for i, id := range spec.Names {
+			lhs[i] = id
+		}
+		return []ast.Stmt{&ast.AssignStmt{Lhs: lhs, Tok: token.ASSIGN, Rhs: spec.Values}}
+	}
+	for _, id := range spec.Names {
+		if !isUnderline(id) {
+			obj := r.pkg.TypeInfo().Defs[id]
+			stmts = append(stmts, X.Assign(token.ASSIGN, id, r.hoistedZero(id, obj.Type())))
+		}
+	}
+	return
+}
+
+func (r *yieldRewriter) hoistedTypeExpr(id *ast.Ident, t types.Type) ast.Expr {
+	var x ast.Expr
+	r.inexpressible(id, func() { x = r.typeExpr(t) })
+	return x
+}
+
+func (r *yieldRewriter) hoistedZero(id *ast.Ident, t types.Type) ast.Expr {
+	var x ast.Expr
+	r.inexpressible(id, func() { x = r.zero(t) })
+	return x
+}
+
+// the type of the hoisted var must be expressible in the scope before the dispatch
+func (r *yieldRewriter) inexpressible(id *ast.Ident, fn func()) {
+	defer func() {
+		if p := recover(); p != nil {
+			reason, ok := p.(fallback)
+			if !ok {
+				panic(p)
+			}
+			r.assert(false, id, CodeUnsupported,
+				"%s declared between goto labels and referred after the next label can't be hoisted, %s", id.Name, reason)
+		}
+	}()
+	fn()
+}
+
+func definedIdents(s *ast.AssignStmt) (ids []*ast.Ident) {
+	if s.Tok != token.DEFINE {
+		return nil
+	}
+	for _, lhs := range s.Lhs {
+		if id, ok := lhs.(*ast.Ident); ok {
+			ids = append(ids, id)
+		}
+	}
+	return
+}
+
+func specIdents(spec ast.Spec) []*ast.Ident {
+	switch spec := spec.(type) {
+	case *ast.ValueSpec:
+		return spec.Names
+	case *ast.TypeSpec:
+		return []*ast.Ident{spec.Name}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package rewriter
+
+import (
+	"bufio"
+	"bytes"
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/goghcrow/go-loader"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// mapStmtPos maps the generated stmts to the position of the original stmt rewritten,
+// e.g., the return of Bind to the yield stmt
+func (r *rewriter) mapStmtPos(generated []ast.Stmt, orig ast.Stmt) {
+	if !r.lines || !orig.Pos().IsValid() {
+		return
+	}
+	for _, s := range generated {
+		if !s.Pos().IsValid() {
+			r.stmtPos[s] = orig.Pos()
+		}
+	}
+}
+
+// formatWithLines is formatWithComment with the //line directives of the original positions,
+// emitted before every stmt, e.g., moved into the closure of Delay / Bind / For,
+// and every top level decl, the filename is relative to the dir of dst, i.e., the generated file
+//
+//		case InOrder:
+//	//line tree_co.go:34
+//			return ʂɘʠ.BindFrom[V](Walk(n.Left, mode), func() ʂɘʠ.Seq[V] {
+func formatWithLines(f *loader.File, comment, dst string, stmtPos map[ast.Stmt]token.Pos) []byte {
+	filename := f.Filename
+	if rel, err := filepath.Rel(filepath.Dir(dst), filename); err == nil {
+		filename = filepath.ToSlash(rel)
+	}
+
+	restore := markLines(f, filename, stmtPos)
+	src := formatWithComment(f, comment)
+	restore()
+
+	// the blank lines are moved before the directive, which applies to the next line
+	return lineMarker.ReplaceAllFunc(src, func(m []byte) []byte {
+		sub := lineMarker.FindSubmatch(m)
+		return []byte(string(sub[2]) + "//line " + filename + ":" + string(sub[1]) + "\n")
+	})
+}
+
+var lineMarker = regexp.MustCompile(`(?m)^[ \t]*` + cstLineMarker + `(\d+)\n((?:[ \t]*\n)*)`)
+
+// markLines inserts the marker stmt before every stmt with the original position,
+// which is printed in a separate line, and the directive to the doc of top level decl,
+// returns the func to restore the file
+func markLines(f *loader.File, filename string, stmtPos map[ast.Stmt]token.Pos) (restore func()) {
+	var undo []func()
+	fset := f.Pkg.Fset
+	line := func(pos token.Pos) int {
+		if !pos.IsValid() {
+			return 0
+		}
+		return fset.Position(pos).Line
+	}
+
+	mark := func(list *[]ast.Stmt) {
+		old := *list
+		xs := make([]ast.Stmt, 0, len(old)*2)
+		for _, s := range old {
+			switch s.(type) {
+			case *ast.CaseClause, *ast.CommClause:
+				// the body of switch / select, the stmts of clauses are marked
+			default:
+				pos := s.Pos()
+				if !pos.IsValid() {
+					pos = stmtPos[s]
+				}
+				if l := line(pos); l > 0 {
+					xs = append(xs, X.Stmt(X.Ident(cstLineMarker+strconv.Itoa(l))))
+				}
+			}
+			xs = append(xs, s)
+		}
+		*list = xs
+		undo = append(undo, func() { *list = old })
+	}
+	astutil.Apply(f.File, func(c *astutil.Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.BlockStmt:
+			// the block in one line, e.g., func() { f() }, kept
+			if n.Lbrace.IsValid() && line(n.Lbrace) == line(n.Rbrace) {
+				return true
+			}
+			mark(&n.List)
+		case *ast.CaseClause:
+			mark(&n.Body)
+		case *ast.CommClause:
+			mark(&n.Body)
+		}
+		return true
+	}, nil)
+
+	doc := func(doc **ast.CommentGroup, pos token.Pos) {
+		old := *doc
+		g := &ast.CommentGroup{}
+		if old != nil {
+			g.List = append(g.List, old.List...)
+		}
+		g.List = append(g.List, &ast.Comment{
+			Slash: pos - 1,
+			Text:  "//line " + filename + ":" + strconv.Itoa(line(pos)),
+		})
+		*doc = g
+		undo = append(undo, func() { *doc = old })
+	}
+	var prev ast.Decl
+	for _, decl := range f.File.Decls {
+		// the adjacent one line decls are kept in a group for the alignment
+		adjacent := prev != nil && line(prev.Pos()) == line(prev.End()) && line(prev.End())+1 == line(decl.Pos())
+		prev = decl
+		if adjacent {
+			continue
+		}
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			doc(&decl.Doc, decl.Pos())
+		case *ast.GenDecl:
+			if decl.Tok != token.IMPORT {
+				doc(&decl.Doc, decl.Pos())
+			}
+		}
+	}
+
+	return func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}
+}
+
+// trimLineDirectives removes the //line directives not changing the positions,
+// i.e., the lines followed are numbered continuously from the previous directive
+func trimLineDirectives(src []byte) []byte {
+	var (
+		buf      bytes.Buffer
+		filename string
+		next     int // the line of the next line, 0 if not in the directive
+	)
+	sc := bufio.NewScanner(bytes.NewReader(src))
+	sc.Buffer(nil, len(src)+1)
+	for sc.Scan() {
+		text := sc.Text()
+		if m := lineDirective.FindStringSubmatch(text); m != nil {
+			l, _ := strconv.Atoi(m[2])
+			if m[1] == filename && l == next {
+				continue
+			}
+			filename, next = m[1], l
+		} else if next > 0 {
+			next++
+		}
+		buf.WriteString(text)
+		buf.WriteByte('\n')
+	}
+	if !bytes.HasSuffix(src, []byte("\n")) {
+		buf.Truncate(buf.Len() - 1)
+	}
+	return buf.Bytes()
+}
+
+var lineDirective = regexp.MustCompile(`^//line (.+):(\d+)$`)
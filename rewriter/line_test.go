@@ -0,0 +1,58 @@
+package rewriter
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"testing"
+)
+
+func TestLineDirectives(t *testing.T) {
+	in := "../example/tree"
+	out := t.TempDir()
+	if err := GoGen(in, WithOutputDir(out), WithLineDirectives()); err != nil {
+		t.Fatal(err)
+	}
+
+	coFile, _ := filepath.Abs(filepath.Join(in, "tree_co.go"))
+	fset := token.NewFileSet()
+	co, err := parser.ParseFile(fset, coFile, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the lines of the calls in the co file
+	lines := map[string]map[int]bool{}
+	calls := func(f *ast.File, fn func(name string, pos token.Position)) {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpr); ok {
+				if id, ok := call.Fun.(*ast.Ident); ok && (id.Name == "Walk" || id.Name == "panic") {
+					fn(id.Name, fset.Position(call.Pos()))
+				}
+			}
+			return true
+		})
+	}
+	calls(co, func(name string, pos token.Position) {
+		if lines[name] == nil {
+			lines[name] = map[int]bool{}
+		}
+		lines[name][pos.Line] = true
+	})
+
+	// the positions of the generated file are adjusted by the //line directives
+	gen, err := parser.ParseFile(fset, filepath.Join(out, "tree.go"), nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	calls(gen, func(name string, pos token.Position) {
+		n++
+		if pos.Filename != coFile || !lines[name][pos.Line] {
+			t.Errorf("%s at %s, expect in %s", name, pos, coFile)
+		}
+	})
+	if n == 0 {
+		t.Fatal("no calls")
+	}
+}
@@ -0,0 +1,1243 @@
+package rewriter
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+
+	"github.com/goghcrow/go-imports"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓ State Machine ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓
+
+// the alternative of pass2 and pass3, selected by WithStateMachine,
+// the yield func is compiled into the body of seq.Machine, which is re-entered on every resume,
+// like MoveNext of the C# iterator
+//
+//	func(n int) Iter[int] {
+//		for i := 0; i < n; i++ {
+//			Yield(i)
+//		}
+//		return
+//	}
+//
+// =>
+//
+//	func(n int) seq.Iterator[int] {
+//		return seq.Start[int](seq.Delay[int](func() seq.Seq[int] {
+//			var i int
+//			return seq.StateMachine[int](func(ʍ *seq.Machine[int]) {
+//				switch ʍ.State {
+//				case 1:
+//					goto ʟ3
+//				}
+//				i = 0
+//			ʟ1:
+//				if !(i < n) {
+//					goto ʟ2
+//				}
+//				ʍ.Yield(i, 1)
+//				return
+//			ʟ3:
+//				i++
+//				goto ʟ1
+//			ʟ2:
+//				return
+//			})
+//		}))
+//	}
+//
+// the stmts containing yield are flattened into gotos, the others are kept,
+// the vars declared in the flattened stmts are hoisted out of the body,
+// so they survive the suspension, and the name conflicting is renamed,
+// the yield func falls back to the monadic rewriting if not supported
+type machine struct {
+	*yieldRewriter
+	recv *ast.Ident // ʍ
+
+	stmts   []ast.Stmt
+	kept    map[*ast.BlockStmt]ast.Stmt // block wrapping the kept stmt => the kept stmt
+	patches []func()                    // rewriting the kept stmts, applied if not falling back
+	hoisted []*hoistedVar
+	targets []*target // the enclosing flattened stmts, targets of break / continue
+	loops   int       // depth of the enclosing flattened loops
+
+	states []*ast.Ident             // labels of the resuming points
+	labels map[*ast.Ident]bool      // generated labels
+	gotos  map[*ast.BranchStmt]bool // generated gotos
+}
+
+type hoistedVar struct {
+	name    string
+	key     any        // types.Object, or *ast.Ident of the untyped generated var
+	typ     types.Type // nil if typExpr given
+	typExpr ast.Expr
+	perIter bool // declared in loop, which is fresh per iteration
+}
+
+type target struct {
+	label     string // empty if unlabeled
+	loop      bool   // for, targeted by continue
+	block     bool   // labeled stmt other than for / switch, targeted only by labeled break
+	brk, cont *ast.Ident
+}
+
+// fallback is the reason why the yield func can't be compiled to state machine
+type fallback string
+
+func (r *yieldRewriter) compileMachine() (body *ast.BlockStmt, ok bool) {
+	m := &machine{
+		yieldRewriter: r,
+		recv:          X.Ident(cstMachineVar),
+		kept:          map[*ast.BlockStmt]ast.Stmt{},
+		labels:        map[*ast.Ident]bool{},
+		gotos:         map[*ast.BranchStmt]bool{},
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			reason, ok := p.(fallback)
+			if !ok {
+				panic(p)
+			}
+			r.rewriter.logf("fallback to monadic rewriting, %s in: %s\n", reason, r.pkg.ShowPos(r.funcTyp))
+			body = nil
+		}
+	}()
+
+	if len(r.collectGotoLabels(r.funcBody)) > 0 {
+		panic(fallback("goto"))
+	}
+	m.flattenStmts(r.funcBody.List)
+	return m.finish(), true
+}
+
+// ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓ Flatten ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓
+
+func (m *machine) flattenStmts(stmts []ast.Stmt) {
+	for _, stmt := range stmts {
+		m.flattenStmt(stmt)
+	}
+}
+
+func (m *machine) flattenStmt(stmt ast.Stmt) {
+	if !m.suspends(stmt) {
+		m.keep(stmt)
+		return
+	}
+
+	switch s := stmt.(type) {
+	case *ast.BlockStmt:
+		m.flattenStmts(s.List)
+
+	case *ast.ExprStmt:
+		call, ok := m.rewriter.isYieldCall(m.pkg, s)
+		if !ok {
+			m.assertNoYieldExpr(s)
+		}
+		m.checkYieldCall(call)
+		m.flattenYieldCall(call)
+
+	case *ast.AssignStmt:
+		assign, call, ok := m.rewriter.isYieldRecvCall(m.pkg, s)
+		if !ok {
+			m.assertNoYieldExpr(s)
+		}
+		m.checkYieldCall(call)
+		m.checkYieldRecvCall(call)
+		m.flattenYieldRecvCall(assign, call)
+
+	case *ast.IfStmt:
+		m.flattenIfStmt(s)
+
+	case *ast.ForStmt:
+		m.flattenForStmt(s, "")
+
+	case *ast.SwitchStmt:
+		m.flattenSwitchStmt(s, "")
+
+	case *ast.LabeledStmt:
+		switch s1 := s.Stmt.(type) {
+		case *ast.ForStmt:
+			m.flattenForStmt(s1, s.Label.Name)
+		case *ast.SwitchStmt:
+			m.flattenSwitchStmt(s1, s.Label.Name)
+		default:
+			// break with label completes the labeled stmt, like seq.Labeled,
+			// e.g., block as the goto target, the invalid break label is reported by checkBreakLabel
+			t := &target{label: s.Label.Name, block: true, brk: m.newLabel()}
+			m.targets = append(m.targets, t)
+			m.flattenStmt(s1)
+			m.targets = m.targets[:len(m.targets)-1]
+			m.emitLabel(t.brk)
+		}
+
+	case *ast.TypeSwitchStmt:
+		panic(fallback("yield in type switch"))
+
+	case *ast.SelectStmt:
+		panic(fallback("yield in select"))
+
+	default:
+		m.assertNoYieldExpr(s)
+	}
+}
+
+// Yield($v)
+// =>
+// ʍ.Yield($v, n)
+// return
+// ʟn:
+//
+// YieldFrom($it)
+// =>
+// ʍ.YieldFrom($it, n)
+// return
+// ʟn:
+func (m *machine) flattenYieldCall(call *ast.CallExpr) {
+	method, v := cstMachineYield, m.yieldValue(call)
+	if m.rewriter.isYieldFromCall(m.pkg, call) {
+		method = cstMachineFrom
+	}
+	m.suspend(method, v)
+}
+
+// $lhs [:]= Yield($v)
+// =>
+// ʍ.Yield($v, n)
+// return
+// ʟn:
+// $lhs = seq.Received[T](ʍ.Recv)
+func (m *machine) flattenYieldRecvCall(assign *ast.AssignStmt, call *ast.CallExpr) {
+	lhs := assign.Lhs[0]
+	if id, ok := lhs.(*ast.Ident); ok && assign.Tok == token.DEFINE {
+		m.hoist(id, nil)
+	}
+	m.suspend(cstMachineYield, call.Args[0])
+	received := X.Index(m.SeqSelect(cstReceived), m.RecvType())
+	m.emit(X.Assign(token.ASSIGN, lhs, X.Call(received, X.Select(m.recv, cstMachineRecv))))
+}
+
+func (m *machine) suspend(method string, v ast.Expr) {
+	label := m.newLabel()
+	m.states = append(m.states, label)
+	state := &ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(len(m.states))}
+	m.emit(X.Stmt(X.Call(X.Select(m.recv, method), v, state)))
+	m.emit(X.Return())
+	m.emitLabel(label)
+}
+
+// if $cond { $body } else { $else }
+// =>
+//
+//	if !($cond) {
+//		goto ʟelse
+//	}
+//
+// $body
+// goto ʟend
+// ʟelse:
+// $else
+// ʟend:
+func (m *machine) flattenIfStmt(s *ast.IfStmt) {
+	if s.Init != nil {
+		m.flattenStmt(s.Init)
+	}
+	end := m.newLabel()
+	els := end
+	if s.Else != nil {
+		els = m.newLabel()
+	}
+	m.emitGotoIf(not(s.Cond), els)
+	m.flattenStmts(s.Body.List)
+	if s.Else != nil {
+		m.emitGoto(end)
+		m.emitLabel(els)
+		m.flattenStmt(s.Else)
+	}
+	m.emitLabel(end)
+}
+
+// for ; $cond; $post { $body }
+// =>
+// ʟhead:
+//
+//	if !($cond) {
+//		goto ʟbreak
+//	}
+//
+// $body
+// ʟcontinue:
+// $post
+// goto ʟhead
+// ʟbreak:
+func (m *machine) flattenForStmt(s *ast.ForStmt, label string) {
+	if s.Init != nil {
+		m.flattenStmt(s.Init)
+	}
+	t := &target{
+		label: label,
+		loop:  true,
+		brk:   m.newLabel(),
+		cont:  m.newLabel(),
+	}
+	head := m.newLabel()
+	m.emitLabel(head)
+	if s.Cond != nil {
+		m.emitGotoIf(not(s.Cond), t.brk)
+	}
+
+	m.targets = append(m.targets, t)
+	m.loops++
+	m.flattenStmts(s.Body.List)
+	m.emitLabel(t.cont)
+	if s.Post != nil {
+		m.flattenStmt(s.Post)
+	}
+	m.loops--
+	m.targets = m.targets[:len(m.targets)-1]
+
+	m.emitGoto(head)
+	m.emitLabel(t.brk)
+}
+
+// the case exprs are evaluated in order, and the tag is evaluated once
+//
+//	switch $tag {
+//	case $a, $b:
+//		$body_ab
+//	default:
+//		$body_default
+//	}
+//	=>
+//	ç = $tag
+//	if ç == $a || ç == $b {
+//		goto ʟab
+//	}
+//	goto ʟdefault
+//	ʟab:
+//	$body_ab
+//	goto ʟbreak
+//	ʟdefault:
+//	$body_default
+//	ʟbreak:
+func (m *machine) flattenSwitchStmt(s *ast.SwitchStmt, label string) {
+	for _, it := range s.Body.List {
+		if isFallthrough(it.(*ast.CaseClause).Body) {
+			panic(fallback("fallthrough in switch containing yield"))
+		}
+	}
+	if s.Init != nil {
+		m.flattenStmt(s.Init)
+	}
+
+	var tag ast.Expr
+	if s.Tag != nil {
+		tag = m.temp(cstCaseVar, types.Default(m.pkg.TypeOf(s.Tag)))
+		m.emit(X.Assign(token.ASSIGN, tag, s.Tag))
+	}
+
+	t := &target{label: label, brk: m.newLabel()}
+	clauses := make([]*ast.Ident, len(s.Body.List))
+	dflt := t.brk
+	for i, it := range s.Body.List {
+		clause := it.(*ast.CaseClause)
+		clauses[i] = m.newLabel()
+		if clause.List == nil {
+			dflt = clauses[i]
+			continue
+		}
+		var cond ast.Expr
+		for _, x := range clause.List {
+			if tag != nil {
+				x = &ast.BinaryExpr{X: tag, Op: token.EQL, Y: parenIfLower(x, token.EQL)}
+			}
+			if cond == nil {
+				cond = x
+			} else {
+				cond = &ast.BinaryExpr{X: cond, Op: token.LOR, Y: parenIfLower(x, token.LOR)}
+			}
+		}
+		m.emitGotoIf(cond, clauses[i])
+	}
+	m.emitGoto(dflt)
+
+	m.targets = append(m.targets, t)
+	for i, it := range s.Body.List {
+		m.emitLabel(clauses[i])
+		m.flattenStmts(it.(*ast.CaseClause).Body)
+		if i < len(clauses)-1 {
+			m.emitGoto(t.brk)
+		}
+	}
+	m.targets = m.targets[:len(m.targets)-1]
+	m.emitLabel(t.brk)
+}
+
+// the stmt without yield is kept,
+// the var declared is hoisted, and the define is rewritten to assignment,
+// return / defer / break / continue in it are rewritten after flattening
+func (m *machine) keep(stmt ast.Stmt) {
+	var stmts []ast.Stmt
+	switch s := stmt.(type) {
+	case *ast.EmptyStmt:
+		return
+	case *ast.DeclStmt:
+		stmts = m.hoistDecl(s)
+	case *ast.AssignStmt:
+		if s.Tok == token.DEFINE {
+			stmts = []ast.Stmt{m.hoistDefine(s)}
+		} else {
+			stmts = []ast.Stmt{s}
+		}
+	default:
+		stmts = []ast.Stmt{s}
+	}
+
+	b := X.Block(stmts...)
+	m.kept[b] = stmt
+	targets := append([]*target(nil), m.targets...)
+	m.patches = append(m.patches, func() { m.rewriteKept(b, targets) })
+	m.emit(b)
+}
+
+// ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓ Hoist ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓
+
+// var $a, $b T = $x, $y
+// =>
+// $a, $b = $x, $y
+func (m *machine) hoistDecl(s *ast.DeclStmt) (stmts []ast.Stmt) {
+	decl := s.Decl.(*ast.GenDecl)
+	if decl.Tok != token.VAR {
+		panic(fallback("local " + decl.Tok.String() + " declaration"))
+	}
+	for _, it := range decl.Specs {
+		spec := it.(*ast.ValueSpec)
+		var lhs []ast.Expr
+		for _, id := range spec.Names {
+			if isUnderline(id) {
+				lhs = append(lhs, id)
+				continue
+			}
+			v := m.hoist(id, nil)
+			lhs = append(lhs, id)
+			if spec.Values == nil && v.perIter {
+				// zero the var declared in loop on every iteration
+				stmts = append(stmts, X.Assign(token.ASSIGN, id, m.zero(v.typ)))
+			}
+		}
+		if spec.Values != nil {
+			stmts = append(stmts, &ast.AssignStmt{Lhs: lhs, Tok: token.ASSIGN, Rhs: spec.Values})
+		}
+	}
+	return
+}
+
+// $a, $b := $x, $y
+// =>
+// $a, $b = $x, $y
+func (m *machine) hoistDefine(s *ast.AssignStmt) ast.Stmt {
+	for i, lhs := range s.Lhs {
+		id := lhs.(*ast.Ident)
+		if isUnderline(id) || !m.isNewVar(id) {
+			continue
+		}
+		var init ast.Expr
+		if len(s.Lhs) == len(s.Rhs) {
+			init = s.Rhs[i]
+		}
+		m.hoist(id, init)
+	}
+	return &ast.AssignStmt{Lhs: s.Lhs, Tok: token.ASSIGN, Rhs: s.Rhs}
+}
+
+// declared by the define, or generated by the rewriting before
+func (m *machine) isNewVar(id *ast.Ident) bool {
+	if m.pkg.TypeInfo().Defs[id] != nil {
+		return true
+	}
+	obj := m.pkg.ObjectOf(id)
+	return obj == nil || obj.Pos() == token.NoPos
+}
+
+func (m *machine) hoist(id *ast.Ident, init ast.Expr) *hoistedVar {
+	v := &hoistedVar{
+		name:    id.Name,
+		key:     keyOf(m.pkg.ObjectOf(id), id),
+		perIter: m.loops > 0,
+	}
+	if obj := m.pkg.ObjectOf(id); obj != nil {
+		v.typ = obj.Type()
+	} else {
+		// the untyped iterator of ranging generated in pass1
+		v.typExpr = m.rangeIterType(init)
+	}
+	m.hoisted = append(m.hoisted, v)
+	return v
+}
+
+// temp var hoisted, all the refs share the ident
+func (m *machine) temp(name string, t types.Type) *ast.Ident {
+	id := X.Ident(name)
+	m.hoisted = append(m.hoisted, &hoistedVar{name: name, key: id, typ: t})
+	return id
+}
+
+func keyOf(obj types.Object, id *ast.Ident) any {
+	if obj != nil {
+		return obj
+	}
+	return id
+}
+
+// ɪʇ := seq.NewXXXIter($x)
+func (m *machine) rangeIterType(init ast.Expr) ast.Expr {
+	call, ok := init.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 {
+		panic(fallback("untyped var"))
+	}
+	var ctor string
+	switch f := call.Fun.(type) {
+	case *ast.SelectorExpr:
+		ctor = f.Sel.Name
+	case *ast.Ident:
+		ctor = f.Name
+	}
+
+	arg := call.Args[0]
+	if s, ok := arg.(*ast.SliceExpr); ok && m.pkg.TypeOf(s) == nil {
+		arg = s.X // array wrapped
+	}
+	var (
+		ty     = m.pkg.TypeOf(arg).Underlying()
+		anyTy  = types.Universe.Lookup("any").Type()
+		intTy  = types.Typ[types.Int]
+		params = func() *types.Tuple {
+			return ty.(*types.Signature).Params().At(0).Type().Underlying().(*types.Signature).Params()
+		}
+		k, v types.Type
+	)
+	switch ctor {
+	case cstNewIntegerIter:
+		k, v = intTy, anyTy
+	case cstNewStringIter:
+		k, v = intTy, types.Universe.Lookup("rune").Type()
+	case cstNewSliceIter:
+		switch ty := ty.(type) {
+		case *types.Slice:
+			k, v = intTy, ty.Elem()
+		case *types.Array:
+			k, v = intTy, ty.Elem()
+		}
+	case cstNewMapIter:
+		k, v = ty.(*types.Map).Key(), ty.(*types.Map).Elem()
+	case cstNewChanIter:
+		k, v = ty.(*types.Chan).Elem(), anyTy
+	case cstNewFunc0Iter:
+		k, v = anyTy, anyTy
+	case cstNewFuncIter:
+		k, v = params().At(0).Type(), anyTy
+	case cstNewFunc2Iter:
+		k, v = params().At(0).Type(), params().At(1).Type()
+	}
+	if k == nil || v == nil {
+		panic(fallback("untyped var"))
+	}
+	iter := cstIterator
+	switch ctor {
+	case cstNewFunc0Iter, cstNewFuncIter, cstNewFunc2Iter:
+		iter = cstClosableIterator // closed on early exit
+	}
+	return X.Index(m.SeqSelect(iter), m.rewriter.pairType(m.typeExpr(k), m.typeExpr(v)))
+}
+
+// ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓ Rewrite Kept ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓
+
+func (m *machine) rewriteKept(b *ast.BlockStmt, targets []*target) {
+	var (
+		loops    int // nested loops, targets of unlabeled break and continue
+		breakers int // nested switch / select, targets of unlabeled break
+		labels   = map[string]bool{}
+	)
+	astutil.Apply(b, func(c *astutil.Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ForStmt, *ast.RangeStmt:
+			loops++
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			breakers++
+		case *ast.LabeledStmt:
+			labels[n.Label.Name] = true
+		}
+		return true
+	}, func(c *astutil.Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.ForStmt, *ast.RangeStmt:
+			loops--
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			breakers--
+		case *ast.ReturnStmt:
+			replace(c, m.rewriteReturn(n)...)
+		case *ast.DeferStmt:
+			replace(c, m.rewriteDefer(n))
+		case *ast.BranchStmt:
+			if n.Label != nil && labels[n.Label.Name] {
+				return true // kept natively
+			}
+			var to *ast.Ident
+			switch n.Tok {
+			case token.BREAK:
+				if n.Label != nil || loops+breakers == 0 {
+					to = findTarget(targets, n.Label, false).brk
+				}
+			case token.CONTINUE:
+				if n.Label != nil || loops == 0 {
+					to = findTarget(targets, n.Label, true).cont
+				}
+			}
+			if to != nil {
+				c.Replace(m.gotoStmt(to))
+			}
+		}
+		return true
+	})
+}
+
+func findTarget(targets []*target, label *ast.Ident, loop bool) *target {
+	for i := len(targets) - 1; i >= 0; i-- {
+		t := targets[i]
+		switch {
+		case label != nil && t.label != label.Name:
+		case loop && !t.loop:
+		case label == nil && t.block:
+		default:
+			return t
+		}
+	}
+	panic("illegal state")
+}
+
+// the returns are rewritten to seq.Return / seq.ReturnValue / seq.ReturnError in pass0
+//
+//	return seq.ReturnValue[T, R]($x)
+//	=>
+//	ʍ.ReturnValue(R($x))
+//	return
+func (m *machine) rewriteReturn(ret *ast.ReturnStmt) []ast.Stmt {
+	call := ret.Results[0].(*ast.CallExpr)
+	var fun ast.Expr
+	switch f := call.Fun.(type) {
+	case *ast.IndexExpr:
+		fun = f.X
+	case *ast.IndexListExpr:
+		fun = f.X
+	}
+	var name string
+	switch f := fun.(type) {
+	case *ast.SelectorExpr:
+		name = f.Sel.Name
+	case *ast.Ident:
+		name = f.Name
+	}
+
+	switch name {
+	case cstReturn:
+		return []ast.Stmt{X.Return()}
+	case cstReturnV:
+		x := call.Args[0]
+		if !types.Identical(m.pkg.TypeOf(x), m.pkg.TypeOf(m.funResultTy)) {
+			// the result is stored as any, so typed as R
+			x = X.Call(parenType(m.funResultTy), x)
+		}
+		return []ast.Stmt{X.Stmt(X.Call(X.Select(m.recv, cstReturnV), x)), X.Return()}
+	case cstReturnE:
+		return []ast.Stmt{X.Stmt(X.Call(X.Select(m.recv, cstReturnE), call.Args[0])), X.Return()}
+	}
+	m.assert(false, ret, CodeInternal, "illegal return")
+	return nil
+}
+
+// the deferred call is pushed to the defer stack of the generator by ʍ.Defer
+//
+//	defer $f($args...)
+//	=>
+//	{
+//		ɗ, ɗ0, ɗ1 := $f, $arg0, $arg1
+//		ʍ.Defer(func() { ɗ(ɗ0, ɗ1) })
+//	}
+func (m *machine) rewriteDefer(stmt *ast.DeferStmt) ast.Stmt {
+	eval, thunk := m.deferredThunk(stmt.Call)
+	callDefer := X.Stmt(X.Call(X.Select(m.recv, cstMachineDefer), thunk))
+	if eval == nil {
+		return callDefer
+	}
+	// the block isolates the evaluated for the defer in loop
+	return X.Block(eval, callDefer)
+}
+
+func replace(c *astutil.Cursor, stmts ...ast.Stmt) {
+	if len(stmts) == 1 {
+		c.Replace(stmts[0])
+		return
+	}
+	if c.Index() < 0 {
+		c.Replace(X.Block(stmts...))
+		return
+	}
+	for _, s := range stmts[:len(stmts)-1] {
+		c.InsertBefore(s)
+	}
+	c.Replace(stmts[len(stmts)-1])
+}
+
+// ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓ Finish ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓
+
+//	{
+//		var ( $hoisted... )
+//		return seq.StateMachine[T](func(ʍ *seq.Machine[T]) {
+//			switch ʍ.State {
+//			case 1:
+//				goto ʟ1
+//			...
+//			}
+//			$flattened
+//		})
+//	}
+func (m *machine) finish() *ast.BlockStmt {
+	m.checkPerIterVars()
+
+	specs := make([]ast.Spec, len(m.hoisted))
+	for i, v := range m.hoisted {
+		typ := v.typExpr
+		if typ == nil {
+			typ = m.typeExpr(v.typ)
+		}
+		specs[i] = &ast.ValueSpec{Names: []*ast.Ident{X.Ident(v.name)}, Type: typ}
+	}
+
+	// no falling back since here, the yield func is modified
+
+	for _, patch := range m.patches {
+		patch()
+	}
+	m.renameHoisted(specs)
+
+	var stmts []ast.Stmt
+	if len(m.states) > 0 {
+		var cases []ast.Stmt
+		for i, label := range m.states {
+			state := &ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(i + 1)}
+			cases = append(cases, X.Case([]ast.Expr{state}, []ast.Stmt{m.gotoStmt(label)}))
+		}
+		stmts = append(stmts, X.Switch(nil, X.Select(m.recv, cstMachineState), X.Block(cases...)))
+	}
+	for _, s := range m.stmts {
+		if b, ok := s.(*ast.BlockStmt); ok && m.kept[b] != nil {
+			stmts = append(stmts, b.List...)
+		} else {
+			stmts = append(stmts, s)
+		}
+	}
+	stmts = m.tidyLabels(m.cleanLabels(stmts))
+
+	machineTy := X.Index(m.SeqSelect(cstMachine), m.funRetParamTy)
+	body := &ast.FuncLit{
+		Type: &ast.FuncType{
+			Params: X.Fields(&ast.Field{
+				Names: []*ast.Ident{m.recv},
+				Type:  &ast.StarExpr{X: machineTy},
+			}),
+		},
+		Body: X.Block(stmts...),
+	}
+	callMachine := X.Call(m.SeqFun(cstStateMachine), body)
+
+	delay := X.Block(X.Return(callMachine))
+	if len(specs) > 0 {
+		decl := &ast.DeclStmt{Decl: &ast.GenDecl{Tok: token.VAR, Specs: specs}}
+		delay.List = []ast.Stmt{decl, X.Return(callMachine)}
+	}
+	return delay
+}
+
+// the var declared in the loop is fresh per iteration,
+// which can't be hoisted if it may be referenced after the iteration
+func (m *machine) checkPerIterVars() {
+	perIter := map[any]bool{}
+	for _, v := range m.hoisted {
+		if v.perIter {
+			perIter[v.key] = true
+		}
+	}
+	if len(perIter) == 0 {
+		return
+	}
+	isPerIter := func(e ast.Expr) bool {
+		id, ok := astutil.Unparen(e).(*ast.Ident)
+		return ok && perIter[keyOf(m.pkg.ObjectOf(id), id)]
+	}
+	info := m.pkg.TypeInfo()
+	ast.Inspect(m.funcBody, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			ast.Inspect(n.Body, func(n ast.Node) bool {
+				if id, ok := n.(*ast.Ident); ok && isPerIter(id) {
+					panic(fallback("var declared in loop captured by closure"))
+				}
+				return true
+			})
+			return false
+		case *ast.UnaryExpr:
+			if n.Op == token.AND && isPerIter(n.X) {
+				panic(fallback("address of var declared in loop"))
+			}
+		case *ast.SelectorExpr:
+			// method with pointer receiver
+			if sel := info.Selections[n]; sel != nil && sel.Kind() == types.MethodVal && isPerIter(n.X) {
+				recv := sel.Obj().Type().(*types.Signature).Recv().Type()
+				_, ptrRecv := recv.(*types.Pointer)
+				_, ptrVar := sel.Recv().Underlying().(*types.Pointer)
+				if ptrRecv && !ptrVar {
+					panic(fallback("address of var declared in loop"))
+				}
+			}
+		case *ast.SliceExpr:
+			if _, ok := m.pkg.TypeOf(n.X).(*types.Array); ok && isPerIter(n.X) {
+				panic(fallback("address of var declared in loop"))
+			}
+		}
+		return true
+	})
+}
+
+// the hoisted var is renamed if the name conflicts with
+// the hoisted before, or the others referenced in the yield func
+func (m *machine) renameHoisted(specs []ast.Spec) {
+	var (
+		refs    = map[string]map[any]bool{} // name => keys
+		hoist   = map[any]bool{}
+		rename  = map[any]string{}
+		inspect = func(root ast.Node) {
+			ast.Inspect(root, func(n ast.Node) bool {
+				switch n := n.(type) {
+				case *ast.SelectorExpr:
+					ast.Inspect(n.X, func(n ast.Node) bool {
+						if id, ok := n.(*ast.Ident); ok {
+							addRef(refs, id.Name, keyOf(m.pkg.ObjectOf(id), id))
+						}
+						return true
+					})
+					return false
+				case *ast.Ident:
+					addRef(refs, n.Name, keyOf(m.pkg.ObjectOf(n), n))
+				}
+				return true
+			})
+		}
+	)
+	for _, v := range m.hoisted {
+		hoist[v.key] = true
+	}
+	inspect(m.funcTyp)
+	inspect(m.funcBody)
+	for _, s := range m.stmts {
+		inspect(s)
+	}
+	for _, s := range specs {
+		inspect(s.(*ast.ValueSpec).Type)
+	}
+	// referenced by the generated
+	addRef(refs, m.recv.Name, m.recv)
+	addRef(refs, m.seqImportedName, m.seqImportedName)
+
+	claimed := map[string]bool{}
+	for i, v := range m.hoisted {
+		conflict := claimed[v.name]
+		for k := range refs[v.name] {
+			conflict = conflict || k != v.key && !hoist[k]
+		}
+		if conflict {
+			name := v.name
+			for n := 1; claimed[name] || len(refs[name]) > 0; n++ {
+				name = v.name + cstRenamed + strconv.Itoa(n)
+			}
+			rename[v.key] = name
+			v.name = name
+			specs[i].(*ast.ValueSpec).Names[0].Name = name
+		}
+		claimed[v.name] = true
+	}
+	if len(rename) == 0 {
+		return
+	}
+
+	do := func(root ast.Node) {
+		ast.Inspect(root, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				if name, ok := rename[keyOf(m.pkg.ObjectOf(id), id)]; ok {
+					id.Name = name
+				}
+			}
+			return true
+		})
+	}
+	do(m.funcBody)
+	for _, s := range m.stmts {
+		do(s)
+	}
+}
+
+func addRef(refs map[string]map[any]bool, name string, key any) {
+	if refs[name] == nil {
+		refs[name] = map[any]bool{}
+	}
+	refs[name][key] = true
+}
+
+// drop the labels not targeted, and the generated gotos unreachable
+func (m *machine) cleanLabels(stmts []ast.Stmt) []ast.Stmt {
+	used := map[string]bool{}
+	for _, s := range stmts {
+		ast.Inspect(s, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.BranchStmt:
+				if n.Tok == token.GOTO {
+					used[n.Label.Name] = true
+				}
+			}
+			return true
+		})
+	}
+
+	var xs []ast.Stmt
+	terminated := false
+	for _, s := range stmts {
+		if l, ok := s.(*ast.LabeledStmt); ok && m.labels[l.Label] && !used[l.Label.Name] {
+			continue
+		}
+		if terminated && m.unreachable(s) {
+			continue
+		}
+		xs = append(xs, s)
+		terminated = m.isTerminating(s)
+	}
+	if len(xs) < len(stmts) {
+		// the label targeted only by the dropped goto
+		return m.cleanLabels(xs)
+	}
+	return xs
+}
+
+// the generated goto and the bare return can be dropped if unreachable,
+// the others are kept as is, even if unreachable, which is the same as the yield func
+func (m *machine) unreachable(s ast.Stmt) bool {
+	switch s := s.(type) {
+	case *ast.BranchStmt:
+		return m.gotos[s]
+	case *ast.ReturnStmt:
+		return true
+	}
+	return false
+}
+
+// attach the labels to the following stmts, and number them in order,
+// the trailing return of the body is dropped
+func (m *machine) tidyLabels(stmts []ast.Stmt) []ast.Stmt {
+	if n := len(stmts); n > 0 {
+		if _, ok := stmts[n-1].(*ast.ReturnStmt); ok {
+			stmts = stmts[:n-1]
+		}
+	}
+
+	var xs []ast.Stmt
+	for i := len(stmts) - 1; i >= 0; i-- {
+		s := stmts[i]
+		l, ok := s.(*ast.LabeledStmt)
+		if ok && m.labels[l.Label] && len(xs) > 0 {
+			l.Stmt = xs[len(xs)-1]
+			xs[len(xs)-1] = l
+		} else {
+			xs = append(xs, s)
+		}
+	}
+	for i, j := 0, len(xs)-1; i < j; i, j = i+1, j-1 {
+		xs[i], xs[j] = xs[j], xs[i]
+	}
+
+	renamed := map[string]string{}
+	for _, s := range xs {
+		for l, ok := s.(*ast.LabeledStmt); ok; l, ok = l.Stmt.(*ast.LabeledStmt) {
+			if m.labels[l.Label] {
+				name := cstMachineLabel + strconv.Itoa(len(renamed)+1)
+				renamed[l.Label.Name] = name
+				l.Label.Name = name
+			}
+		}
+	}
+	for _, s := range xs {
+		ast.Inspect(s, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.BranchStmt:
+				if m.gotos[n] {
+					n.Label.Name = renamed[n.Label.Name]
+				}
+			}
+			return true
+		})
+	}
+	return xs
+}
+
+// ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓ Emit ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓
+
+// the stmt of the flattened body
+func (m *machine) emit(stmt ast.Stmt) {
+	m.stmts = append(m.stmts, stmt)
+}
+
+func (m *machine) newLabel() *ast.Ident {
+	id := X.Ident(cstMachineLabel + strconv.Itoa(len(m.labels)+1))
+	m.labels[id] = true
+	return id
+}
+
+func (m *machine) emitLabel(label *ast.Ident) {
+	m.emit(&ast.LabeledStmt{Label: label, Stmt: &ast.EmptyStmt{Implicit: true}})
+}
+
+func (m *machine) gotoStmt(label *ast.Ident) *ast.BranchStmt {
+	b := &ast.BranchStmt{Tok: token.GOTO, Label: X.Ident(label.Name)}
+	m.gotos[b] = true
+	return b
+}
+
+func (m *machine) emitGoto(label *ast.Ident) {
+	m.emit(m.gotoStmt(label))
+}
+
+func (m *machine) emitGotoIf(cond ast.Expr, label *ast.Ident) {
+	m.emit(X.IfStmt(nil, cond, X.Block(m.gotoStmt(label)), nil))
+}
+
+// whether suspends the body, i.e., containing yield called directly
+func (m *machine) suspends(stmt ast.Stmt) (found bool) {
+	if isNil(stmt) {
+		return false
+	}
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.CallExpr:
+			found = m.rewriter.isYieldCallee(m.pkg.Callee(n))
+		}
+		return !found
+	})
+	return
+}
+
+func not(cond ast.Expr) ast.Expr {
+	switch c := cond.(type) {
+	case *ast.UnaryExpr:
+		if c.Op == token.NOT {
+			return c.X
+		}
+	case *ast.Ident, *ast.CallExpr, *ast.SelectorExpr, *ast.IndexExpr, *ast.ParenExpr:
+		return X.Unary(token.NOT, cond)
+	}
+	return X.Unary(token.NOT, &ast.ParenExpr{X: cond})
+}
+
+// parenthesize the operand of binary op if the precedence is lower or equal
+func parenIfLower(x ast.Expr, op token.Token) ast.Expr {
+	if b, ok := x.(*ast.BinaryExpr); ok && b.Op.Precedence() <= op.Precedence() {
+		return &ast.ParenExpr{X: x}
+	}
+	return x
+}
+
+// parenthesize the type used as conversion, e.g., (*T)(x)
+func parenType(t ast.Expr) ast.Expr {
+	switch t.(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.IndexExpr, *ast.IndexListExpr:
+		return t
+	}
+	return &ast.ParenExpr{X: t}
+}
+
+// ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓ Type Expr ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓
+
+// the type expr of the hoisted var, referring to the imports of the file,
+// and the co types are translated to the seq types like rewriteIter
+func (r *yieldRewriter) typeExpr(t types.Type) ast.Expr {
+	switch t := unalias(t).(type) {
+	case *types.Basic:
+		switch {
+		case t.Kind() == types.Invalid:
+			panic(fallback("invalid type")) // broken type info
+		case t.Info()&types.IsUntyped != 0:
+			panic(fallback("untyped " + t.String()))
+		case t.Kind() == types.UnsafePointer:
+			return r.qualified("unsafe", "unsafe", "Pointer")
+		}
+		return X.Ident(t.Name())
+	case *types.Pointer:
+		return &ast.StarExpr{X: r.typeExpr(t.Elem())}
+	case *types.Slice:
+		return &ast.ArrayType{Elt: r.typeExpr(t.Elem())}
+	case *types.Array:
+		n := &ast.BasicLit{Kind: token.INT, Value: strconv.FormatInt(t.Len(), 10)}
+		return &ast.ArrayType{Len: n, Elt: r.typeExpr(t.Elem())}
+	case *types.Map:
+		return &ast.MapType{Key: r.typeExpr(t.Key()), Value: r.typeExpr(t.Elem())}
+	case *types.Chan:
+		dir := ast.SEND | ast.RECV
+		switch t.Dir() {
+		case types.SendOnly:
+			dir = ast.SEND
+		case types.RecvOnly:
+			dir = ast.RECV
+		}
+		return &ast.ChanType{Dir: dir, Value: r.typeExpr(t.Elem())}
+	case *types.Signature:
+		params := r.fieldsExpr(t.Params())
+		if t.Variadic() {
+			last := params.List[len(params.List)-1]
+			last.Type = &ast.Ellipsis{Elt: last.Type.(*ast.ArrayType).Elt}
+		}
+		return &ast.FuncType{Params: params, Results: r.fieldsExpr(t.Results())}
+	case *types.Struct:
+		var fields []*ast.Field
+		for i := 0; i < t.NumFields(); i++ {
+			f := t.Field(i)
+			if !f.Exported() && f.Pkg() != r.pkg.Types {
+				panic(fallback("unexported field of " + t.String()))
+			}
+			field := &ast.Field{Type: r.typeExpr(f.Type())}
+			if !f.Embedded() {
+				field.Names = []*ast.Ident{X.Ident(f.Name())}
+			}
+			if tag := t.Tag(i); tag != "" {
+				field.Tag = &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(tag)}
+			}
+			fields = append(fields, field)
+		}
+		return &ast.StructType{Fields: X.Fields(fields...)}
+	case *types.Interface:
+		if t.Empty() {
+			return X.Ident("any")
+		}
+		if t.NumEmbeddeds() > 0 {
+			panic(fallback("inexpressible " + t.String()))
+		}
+		var methods []*ast.Field
+		for i := 0; i < t.NumExplicitMethods(); i++ {
+			f := t.ExplicitMethod(i)
+			if !f.Exported() && f.Pkg() != r.pkg.Types {
+				panic(fallback("unexported method of " + t.String()))
+			}
+			methods = append(methods, &ast.Field{
+				Names: []*ast.Ident{X.Ident(f.Name())},
+				Type:  r.typeExpr(f.Type()),
+			})
+		}
+		return &ast.InterfaceType{Methods: X.Fields(methods...)}
+	case *types.TypeParam:
+		return X.Ident(t.Obj().Name())
+	case *types.Named:
+		return r.namedExpr(t)
+	}
+	panic(fallback("inexpressible " + t.String()))
+}
+
+func (r *yieldRewriter) fieldsExpr(t *types.Tuple) *ast.FieldList {
+	fields := X.Fields()
+	for i := 0; i < t.Len(); i++ {
+		fields.List = append(fields.List, X.TypeField(r.typeExpr(t.At(i).Type())))
+	}
+	return fields
+}
+
+func (r *yieldRewriter) namedExpr(t *types.Named) ast.Expr {
+	var args []ast.Expr
+	for i := 0; i < t.TypeArgs().Len(); i++ {
+		args = append(args, r.typeExpr(t.TypeArgs().At(i)))
+	}
+
+	rw := r.rewriter
+	obj := t.Obj()
+	switch types.Object(obj) {
+	case rw.iterType, rw.errIterType:
+		return X.Index(r.SeqSelect(cstIterator), args[0])
+	case rw.iter2Type:
+		return X.Index(r.SeqSelect(cstIterator), rw.pairType(args...))
+	case rw.generatorType:
+		return X.Indices(r.SeqSelect(cstResultIterator), args...)
+	case rw.coroutineType:
+		return X.Indices(r.SeqSelect(cstGenerator), args...)
+	}
+
+	var x ast.Expr
+	switch pkg := obj.Pkg(); {
+	case pkg == nil: // error, comparable
+		x = X.Ident(obj.Name())
+	case obj.Parent() != pkg.Scope():
+		panic(fallback("local type " + obj.Name()))
+	case pkg == r.pkg.Types:
+		x = X.Ident(obj.Name())
+	case !obj.Exported():
+		panic(fallback("unexported type " + t.String()))
+	default:
+		x = r.qualified(pkg.Path(), pkg.Name(), obj.Name())
+	}
+
+	switch len(args) {
+	case 0:
+		return x
+	case 1:
+		return X.Index(x, args[0])
+	default:
+		return X.Indices(x, args...)
+	}
+}
+
+func (r *yieldRewriter) qualified(path, pkgName, name string) ast.Expr {
+	imported := imports.ImportName(r.rewriter.file, path, pkgName)
+	if imported == "" || imported == "_" {
+		panic(fallback("not imported " + path))
+	}
+	return X.PkgSelect(imported, name)
+}
+
+// the zero value of the var declared without value
+func (r *yieldRewriter) zero(t types.Type) ast.Expr {
+	switch u := t.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Kind() == types.Invalid:
+			panic(fallback("invalid type"))
+		case u.Info()&types.IsBoolean != 0:
+			return X.Ident("false")
+		case u.Info()&types.IsNumeric != 0:
+			return &ast.BasicLit{Kind: token.INT, Value: "0"}
+		case u.Info()&types.IsString != 0:
+			return &ast.BasicLit{Kind: token.STRING, Value: `""`}
+		}
+		return X.Ident("nil")
+	case *types.Pointer, *types.Slice, *types.Map, *types.Chan, *types.Signature:
+		return X.Ident("nil")
+	case *types.Interface:
+		if _, ok := t.(*types.TypeParam); !ok {
+			return X.Ident("nil")
+		}
+	case *types.Struct, *types.Array:
+		return &ast.CompositeLit{Type: r.typeExpr(t)}
+	}
+	return &ast.StarExpr{X: X.Call(X.Ident("new"), r.typeExpr(t))}
+}
@@ -126,6 +126,7 @@ func (o *optimizer) optimizeDelayCall() {
 	)
 	noEffectCombinatorCall := calleeOf(
 		cstDelay,
+		cstDefer,
 		cstCombine,
 		cstFor,
 		cstWhile,
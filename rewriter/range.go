@@ -25,60 +25,119 @@ func (r *yieldRewriter) ignoreKeyVal(k, v ast.Expr) (bool, bool) {
 func (r *yieldRewriter) rewriteRanges(block *ast.BlockStmt) {
 	astutil.Apply(block, nil, func(c *astutil.Cursor) bool {
 		switch n := c.Node().(type) {
+		case *ast.LabeledStmt:
+			if rng, ok := n.Stmt.(*ast.RangeStmt); ok {
+				// L: for range $X { ... }
+				// =>
+				// { it := NewXXXIter($X); L: for it.MoveNext() { ... } }
+				r.rewriteRange(rng, n.Label, func(init []ast.Stmt, forStmt *ast.ForStmt) {
+					n.Stmt = forStmt
+					b := X.Block(append(init, n)...)
+					r.initBlocks[b] = true
+					c.Replace(b)
+				})
+			}
 		case *ast.RangeStmt:
-			do := func(ctor string, arg ast.Expr) {
-				factory := r.SeqSelect(ctor)
-				iter := X.Call(factory, arg)
-				init, forStmt := r.rewriteRangeToForIter(n, iter)
-				c.InsertBefore(init)
-				c.Replace(forStmt)
+			if _, ok := c.Parent().(*ast.LabeledStmt); ok {
+				return true // rewrite with label, can't insert init before range stmt
 			}
-
-			ty := r.pkg.TypeOf(n.X)
-			r.assert(!isNil(ty), n.X, "type missing")
-			ty = ty.Underlying()
-
-			switch ty := ty.(type) {
-			case *types.Basic:
-				switch {
-				case ty.Info()&types.IsString != 0:
-					do(cstNewStringIter, n.X)
-				case ty.Info()&types.IsInteger != 0:
-					// >= 1.22 only, but no release, need test
-					do(cstNewIntegerIter, n.X)
+			r.rewriteRange(n, nil, func(init []ast.Stmt, forStmt *ast.ForStmt) {
+				for _, s := range init {
+					c.InsertBefore(s)
 				}
-			case *types.Array:
-				// typing workaround for abstract generic array iter
-				// type can't be infered from array, so we wrap it with slice
-				typeInfered := &ast.SliceExpr{X: n.X}
-				do(cstNewSliceIter, typeInfered)
-			case *types.Slice:
-				do(cstNewSliceIter, n.X)
-			case *types.Map:
-				do(cstNewMapIter, n.X)
-			case *types.Chan:
-				do(cstNewChanIter, n.X)
-			case *types.Signature:
-				panic("implement me: range func")
-			}
+				c.Replace(forStmt)
+			})
 		}
 		return true
 	})
 }
 
-func (r *yieldRewriter) rewriteRangeToForIter(
+func (r *yieldRewriter) rewriteRange(
 	n *ast.RangeStmt,
-	iter ast.Expr,
-) (
-	init *ast.AssignStmt,
-	forStmt *ast.ForStmt,
+	label *ast.Ident,
+	replace func(init []ast.Stmt, forStmt *ast.ForStmt),
 ) {
-	// r.rewriter.NewIdent()
-	it := X.Ident(r.gensym(cstIterVar))
+	do := func(ctor string, arg ast.Expr) {
+		it := X.Ident(r.gensym(cstIterVar))
+		init := []ast.Stmt{X.Define(it, X.Call(r.SeqSelect(ctor), arg))}
+		if ctor == cstNewFunc0Iter || ctor == cstNewFuncIter || ctor == cstNewFunc2Iter {
+			init = append(init, r.stopPulledIter(n, label, it)...)
+		}
+		replace(init, r.rewriteRangeToForIter(n, it))
+	}
+
+	ty := r.pkg.TypeOf(n.X)
+	r.assert(!isNil(ty), n.X, CodeInternal, "type missing")
+	ty = ty.Underlying()
+
+	switch ty := ty.(type) {
+	case *types.Basic:
+		switch {
+		case ty.Info()&types.IsString != 0:
+			do(cstNewStringIter, n.X)
+		case ty.Info()&types.IsInteger != 0:
+			// >= 1.22 only, but no release, need test
+			do(cstNewIntegerIter, n.X)
+		}
+	case *types.Array:
+		// typing workaround for abstract generic array iter
+		// type can't be infered from array, so we wrap it with slice
+		typeInfered := &ast.SliceExpr{X: n.X}
+		do(cstNewSliceIter, typeInfered)
+	case *types.Slice:
+		do(cstNewSliceIter, n.X)
+	case *types.Map:
+		do(cstNewMapIter, n.X)
+	case *types.Chan:
+		do(cstNewChanIter, n.X)
+	case *types.Signature:
+		// >= 1.23 only, func(yield func(...) bool)
+		r.assert(ty.Params().Len() == 1, n.X, CodeRangeFunc, "invalid range func")
+		yield, ok := ty.Params().At(0).Type().Underlying().(*types.Signature)
+		r.assert(ok, n.X, CodeRangeFunc, "invalid range func")
+		switch yield.Params().Len() {
+		case 0:
+			do(cstNewFunc0Iter, n.X)
+		case 1:
+			do(cstNewFuncIter, n.X)
+		case 2:
+			do(cstNewFunc2Iter, n.X)
+		default:
+			r.assert(false, n.X, CodeRangeFunc, "invalid range func")
+		}
+	}
+}
+
+// the push func pulled by iter.Pull is suspended until exhausted or stopped,
+// so the iterator is closed before leaving the loop early, and also deferred
+// if the loop body yields, in case the generator is closed while suspended in the loop,
+// the iterator exhausted or closed already is closed again harmlessly
+//
+//	it := NewFuncIter($X)
+//	defer func() { it.Close() }()
+//	for it.MoveNext() {
+//		if $cond {
+//			it.Close()
+//			break
+//		}
+//		Yield(...)
+//	}
+func (r *yieldRewriter) stopPulledIter(n *ast.RangeStmt, label *ast.Ident, it *ast.Ident) (deferred []ast.Stmt) {
+	closeIt := func() ast.Stmt { return X.Stmt(X.Call(X.Select(it, cstClose))) }
+	r.rewriter.closeOnEarlyExit(n.Body, label, closeIt)
+	if !r.rewriter.containsYield(r.pkg, n.Body) {
+		return nil
+	}
+	thunk := &ast.FuncLit{
+		Type: &ast.FuncType{Params: X.Fields(), Results: X.Fields()},
+		Body: X.Block(closeIt()),
+	}
+	return []ast.Stmt{&ast.DeferStmt{Call: X.Call(thunk)}}
+}
+
+func (r *yieldRewriter) rewriteRangeToForIter(n *ast.RangeStmt, it *ast.Ident) (forStmt *ast.ForStmt) {
 	current := X.Select(it, cstCurrent)
 	next := X.Select(it, cstMoveNext)
-
-	init = X.Define(it, iter)
 	cond := X.Call(next)
 
 	var kv *ast.AssignStmt
@@ -8,7 +8,6 @@ import (
 )
 
 // modified from go/src/go/types/return.go
-// cropping label control flow, cause of no supporting in yield func
 
 type terminationChecker struct {
 	panicCallSites map[*ast.CallExpr]bool
@@ -20,7 +19,9 @@ func mkTerminationChecker(panicCallSites map[*ast.CallExpr]bool) *terminationChe
 	}
 }
 
-func (check *terminationChecker) isTerminating(s ast.Stmt) bool {
+// isTerminating reports if s is a terminating statement.
+// If s is labeled, label is the label name; otherwise s is "".
+func (check *terminationChecker) isTerminating(s ast.Stmt, label string) bool {
 	switch s := s.(type) {
 	default:
 		panic("unreachable")
@@ -31,7 +32,7 @@ func (check *terminationChecker) isTerminating(s ast.Stmt) bool {
 		// no chance
 
 	case *ast.LabeledStmt:
-		return check.isTerminating(s.Stmt)
+		return check.isTerminating(s.Stmt, s.Label.Name)
 
 	case *ast.ExprStmt:
 		// calling the predeclared (possibly parenthesized) panic() function is terminating
@@ -48,25 +49,25 @@ func (check *terminationChecker) isTerminating(s ast.Stmt) bool {
 		}
 
 	case *ast.BlockStmt:
-		return check.isTerminatingList(s.List)
+		return check.isTerminatingList(s.List, "")
 
 	case *ast.IfStmt:
 		if s.Else != nil &&
-			check.isTerminating(s.Body) &&
-			check.isTerminating(s.Else) {
+			check.isTerminating(s.Body, "") &&
+			check.isTerminating(s.Else, "") {
 			return true
 		}
 
 	case *ast.SwitchStmt:
-		return check.isTerminatingSwitch(s.Body)
+		return check.isTerminatingSwitch(s.Body, label)
 
 	case *ast.TypeSwitchStmt:
-		return check.isTerminatingSwitch(s.Body)
+		return check.isTerminatingSwitch(s.Body, label)
 
 	case *ast.SelectStmt:
 		for _, s := range s.Body.List {
 			cc := s.(*ast.CommClause)
-			if !check.isTerminatingList(cc.Body) || hasBreakList(cc.Body) {
+			if !check.isTerminatingList(cc.Body, "") || hasBreakList(cc.Body, label, true) {
 				return false
 			}
 
@@ -74,7 +75,7 @@ func (check *terminationChecker) isTerminating(s ast.Stmt) bool {
 		return true
 
 	case *ast.ForStmt:
-		if s.Cond == nil && !hasBreak(s.Body) {
+		if s.Cond == nil && !hasBreak(s.Body, label, true) {
 			return true
 		}
 	}
@@ -82,77 +83,103 @@ func (check *terminationChecker) isTerminating(s ast.Stmt) bool {
 	return false
 }
 
-func (check *terminationChecker) isTerminatingList(list []ast.Stmt) bool {
+func (check *terminationChecker) isTerminatingList(list []ast.Stmt, label string) bool {
 	// trailing empty statements are permitted - skip them
 	for i := len(list) - 1; i >= 0; i-- {
 		if _, ok := list[i].(*ast.EmptyStmt); !ok {
-			return check.isTerminating(list[i])
+			return check.isTerminating(list[i], label)
 		}
 	}
 	return false // all statements are empty
 }
 
-func (check *terminationChecker) isTerminatingSwitch(body *ast.BlockStmt) bool {
+func (check *terminationChecker) isTerminatingSwitch(body *ast.BlockStmt, label string) bool {
 	hasDefault := false
 	for _, s := range body.List {
 		cc := s.(*ast.CaseClause)
 		if cc.List == nil {
 			hasDefault = true
 		}
-		if !check.isTerminatingList(cc.Body) || hasBreakList(cc.Body) {
+		if !check.isTerminatingList(cc.Body, "") || hasBreakList(cc.Body, label, true) {
 			return false
 		}
 	}
 	return hasDefault
 }
 
-func hasBreak(s ast.Stmt) bool {
+// hasBreak reports if s is or contains a break statement
+// referring to the label-ed statement or implicit-ly the
+// closest outer breakable statement.
+func hasBreak(s ast.Stmt, label string, implicit bool) bool {
 	switch s := s.(type) {
 	default:
 		panic("unreachable")
 
 	case *ast.BadStmt, *ast.DeclStmt, *ast.EmptyStmt, *ast.ExprStmt,
 		*ast.SendStmt, *ast.IncDecStmt, *ast.AssignStmt, *ast.GoStmt,
-		*ast.DeferStmt, *ast.ReturnStmt,
-
-		*ast.SwitchStmt, *ast.TypeSwitchStmt,
-		*ast.SelectStmt, *ast.ForStmt, *ast.RangeStmt:
+		*ast.DeferStmt, *ast.ReturnStmt:
 		// no chance
 
 	case *ast.LabeledStmt:
-		return hasBreak(s.Stmt)
+		return hasBreak(s.Stmt, label, implicit)
 
 	case *ast.BranchStmt:
 		if s.Tok == token.BREAK {
 			if s.Label == nil {
-				panic("labelled break not supported")
+				return implicit
+			}
+			if s.Label.Name == label {
+				return true
 			}
-			return true
 		}
 
 	case *ast.BlockStmt:
-		return hasBreakList(s.List)
+		return hasBreakList(s.List, label, implicit)
 
 	case *ast.IfStmt:
-		if hasBreak(s.Body) ||
-			s.Else != nil && hasBreak(s.Else) {
+		if hasBreak(s.Body, label, implicit) ||
+			s.Else != nil && hasBreak(s.Else, label, implicit) {
 			return true
 		}
 
 	case *ast.CaseClause:
-		return hasBreakList(s.Body)
+		return hasBreakList(s.Body, label, implicit)
+
+	case *ast.SwitchStmt:
+		if label != "" && hasBreak(s.Body, label, false) {
+			return true
+		}
+
+	case *ast.TypeSwitchStmt:
+		if label != "" && hasBreak(s.Body, label, false) {
+			return true
+		}
 
 	case *ast.CommClause:
-		return hasBreakList(s.Body)
+		return hasBreakList(s.Body, label, implicit)
 
+	case *ast.SelectStmt:
+		if label != "" && hasBreak(s.Body, label, false) {
+			return true
+		}
+
+	case *ast.ForStmt:
+		if label != "" && hasBreak(s.Body, label, false) {
+			return true
+		}
+
+	case *ast.RangeStmt:
+		if label != "" && hasBreak(s.Body, label, false) {
+			return true
+		}
 	}
 
 	return false
 }
 
-func hasBreakList(list []ast.Stmt) bool {
+func hasBreakList(list []ast.Stmt, label string, implicit bool) bool {
 	for _, s := range list {
-		if hasBreak(s) {
+		if hasBreak(s, label, implicit) {
 			return true
 		}
 	}
@@ -1,11 +1,11 @@
 package rewriter
 
 import (
-	"fmt"
 	"go/ast"
 	"go/token"
 	"go/types"
 	"log"
+	"strconv"
 
 	"github.com/goghcrow/go-ast-matcher"
 	"github.com/goghcrow/go-imports"
@@ -19,35 +19,100 @@ type rewriter struct {
 
 	// global context
 	iterType      types.Object
+	iter2Type     types.Object
+	errIterType   types.Object
+	generatorType types.Object
+	coroutineType types.Object
 	yieldFunc     types.Object
+	yieldRecvFunc types.Object
+	yield2Func    types.Object
 	yieldFromFunc types.Object
+	returnFunc    types.Object
+	buildTag      string
+	machine       bool // compile yield func to state machine instead of the monadic combinators
+	lines         bool // map the generated stmts to the original positions for the //line directives
+	diags         Diagnostics
+	logf          func(format string, a ...any)
 
 	// file context
+	file            *ast.File
 	coImportedName  string
 	seqImportedName string
+	fileComment     string // header with build constraints
 	yieldFuncDecls  map[*ast.FuncDecl]bool
 	yieldFuncLits   map[*ast.FuncLit]bool
 	comments        []*ast.CommentGroup
+	symCnt          int // for unique closable iterator var
+	stmtPos         map[ast.Stmt]token.Pos
 }
 
-func mkRewriter(m astmatcher.ASTMatcher) *rewriter {
+func mkRewriter(m astmatcher.ASTMatcher, buildTag string) *rewriter {
+	if m.Loader.LookupPackage(pkgCoPath) == nil {
+		// nothing to rewrite, skipped by rewriteAllFiles
+		return &rewriter{m: m, buildTag: buildTag, logf: log.Printf}
+	}
 	return &rewriter{
 		m:             m,
+		buildTag:      buildTag,
 		iterType:      m.Loader.MustLookup(qualifiedIter),
+		iter2Type:     m.Loader.MustLookup(qualifiedIter2),
+		errIterType:   m.Loader.MustLookup(qualifiedErrIter),
+		generatorType: m.Loader.MustLookup(qualifiedGenerator),
+		coroutineType: m.Loader.MustLookup(qualifiedCoroutine),
 		yieldFunc:     m.Loader.MustLookup(qualifiedYield),
+		yieldRecvFunc: m.Loader.MustLookup(qualifiedYieldRecv),
+		yield2Func:    m.Loader.MustLookup(qualifiedYield2),
 		yieldFromFunc: m.Loader.MustLookup(qualifiedYieldFrom),
+		returnFunc:    m.Loader.MustLookup(qualifiedReturn),
+		logf:          log.Printf,
 	}
 }
 
 // Yield is stmt, not expr
 func (r *rewriter) isYieldCall(pkg loader.Pkg, n ast.Node) (*ast.CallExpr, bool) {
 	// e.g. for Yield(1); not here; Yield(2) {  Yield(3) }
-	return r.isCallStmtOf(pkg, n, r.yieldFunc)
+	if call, ok := r.isCallStmtOf(pkg, n, r.yieldFunc); ok {
+		return call, true
+	}
+	if call, ok := r.isCallStmtOf(pkg, n, r.yield2Func); ok {
+		return call, true
+	}
+	// YieldFrom is stmt, not expr
+	if call, ok := r.isCallStmtOf(pkg, n, r.yieldFromFunc); ok {
+		return call, true
+	}
+	return r.isCallStmtOf(pkg, n, r.yieldRecvFunc)
+}
+
+func (r *rewriter) isYield2Call(pkg loader.Pkg, call *ast.CallExpr) bool {
+	return pkg.Callee(call) == r.yield2Func
 }
 
-// YieldFrom is stmt, not expr
-func (r *rewriter) isYieldFromCall(pkg loader.Pkg, n ast.Node) (*ast.CallExpr, bool) {
-	return r.isCallStmtOf(pkg, n, r.yieldFromFunc)
+// Yield / YieldRecv / Yield2 / YieldFrom
+func (r *rewriter) isYieldCallee(callee types.Object) bool {
+	return callee == r.yieldFunc || callee == r.yieldRecvFunc ||
+		callee == r.yield2Func || callee == r.yieldFromFunc
+}
+
+// Yield as expr, only in the form of `v := Yield(x)` or `v = Yield(x)`,
+// the same as YieldRecv
+func (r *rewriter) isYieldRecvCall(pkg loader.Pkg, n ast.Node) (*ast.AssignStmt, *ast.CallExpr, bool) {
+	assign, ok := n.(*ast.AssignStmt)
+	if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 {
+		return nil, nil, false
+	}
+	if assign.Tok != token.DEFINE && assign.Tok != token.ASSIGN {
+		return nil, nil, false
+	}
+	call, ok := r.isCallOf(pkg, assign.Rhs[0], r.yieldFunc)
+	if !ok {
+		call, ok = r.isCallOf(pkg, assign.Rhs[0], r.yieldRecvFunc)
+	}
+	return assign, call, ok
+}
+
+func (r *rewriter) isYieldFromCall(pkg loader.Pkg, call *ast.CallExpr) bool {
+	return pkg.Callee(call) == r.yieldFromFunc
 }
 
 func (r *rewriter) isCallStmtOf(pkg loader.Pkg, n ast.Node, callee types.Object) (*ast.CallExpr, bool) {
@@ -55,7 +120,11 @@ func (r *rewriter) isCallStmtOf(pkg loader.Pkg, n ast.Node, callee types.Object)
 	if !ok {
 		return nil, false
 	}
-	call, ok := expr.X.(*ast.CallExpr)
+	return r.isCallOf(pkg, expr.X, callee)
+}
+
+func (r *rewriter) isCallOf(pkg loader.Pkg, n ast.Expr, callee types.Object) (*ast.CallExpr, bool) {
+	call, ok := n.(*ast.CallExpr)
 	if !ok {
 		return nil, false
 	}
@@ -71,14 +140,78 @@ func (r *rewriter) isYieldFuncLit(f *ast.FuncLit) bool {
 }
 
 func (r *rewriter) yieldFuncRetParamTy(pkg loader.Pkg, f *ast.FuncType) ast.Expr {
+	switch retTy := f.Results.List[0].Type.(type) {
+	case *ast.IndexExpr:
+		return retTy.Index
+	case *ast.IndexListExpr:
+		if r.isIter2(pkg.TypeOf(retTy)) {
+			// co.Iter2[K, V] yields seq.Pair[K, V]
+			return r.pairType(retTy.Indices...)
+		}
+		// co.Generator[Y, R] or co.Coroutine[Y, S, R]
+		return retTy.Indices[0]
+	}
+	r.assert(pkg, false, f, CodeYieldFuncSignature, "invalid yield func type")
+	return nil
+}
+
+// R of co.Generator[Y, R] or co.Coroutine[Y, S, R],
+// nil if yield func returns co.Iter or iter.Seq
+func (r *rewriter) yieldFuncResultTy(pkg loader.Pkg, f *ast.FuncType) ast.Expr {
+	retTy := f.Results.List[0].Type
+	switch ty := pkg.TypeOf(retTy); {
+	case r.isGenerator(ty):
+		return retTy.(*ast.IndexListExpr).Indices[1]
+	case r.isCoroutine(ty):
+		return retTy.(*ast.IndexListExpr).Indices[2]
+	}
+	return nil
+}
+
+// S of co.Coroutine[Y, S, R], nil if yield func returns others
+func (r *rewriter) yieldFuncSendTy(pkg loader.Pkg, f *ast.FuncType) ast.Expr {
 	retTy := f.Results.List[0].Type
-	idx, is := retTy.(*ast.IndexExpr)
-	r.assert(pkg, is, f, "invalid yield func type")
-	return idx.Index
+	if r.isCoroutine(pkg.TypeOf(retTy)) {
+		return retTy.(*ast.IndexListExpr).Indices[1]
+	}
+	return nil
 }
 
+// co.Iter[V], co.Iter2[K, V], co.ErrIter[V], co.Generator[V, R] or co.Coroutine[V, S, R]
 func (r *rewriter) isIterator(ty types.Type) bool {
-	return identicalWithoutTypeParam(r.iterType.Type(), ty)
+	return identicalWithoutTypeParam(r.iterType.Type(), ty) || r.isIter2(ty) || r.isErrIter(ty) ||
+		r.isGenerator(ty) || r.isCoroutine(ty)
+}
+
+func (r *rewriter) isIter2(ty types.Type) bool {
+	return identicalWithoutTypeParam(r.iter2Type.Type(), ty)
+}
+
+// seq.Pair[K, V]
+func (r *rewriter) pairType(kv ...ast.Expr) ast.Expr {
+	return X.Indices(X.PkgSelect(r.seqImportedName, cstPair), kv...)
+}
+
+func (r *rewriter) isErrIter(ty types.Type) bool {
+	return identicalWithoutTypeParam(r.errIterType.Type(), ty)
+}
+
+func (r *rewriter) isGenerator(ty types.Type) bool {
+	return identicalWithoutTypeParam(r.generatorType.Type(), ty)
+}
+
+func (r *rewriter) isCoroutine(ty types.Type) bool {
+	return identicalWithoutTypeParam(r.coroutineType.Type(), ty)
+}
+
+// yield func can also return iter.Seq[V] directly
+func (r *rewriter) isStdSeq(ty types.Type) bool {
+	named, ok := unalias(ty).(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == pkgIterPath && obj.Name() == cstStdSeq
 }
 
 func (r *rewriter) containsYield(pkg loader.Pkg, n *ast.BlockStmt) bool {
@@ -94,9 +227,13 @@ func (r *rewriter) containsYield(pkg loader.Pkg, n *ast.BlockStmt) bool {
 			switch n := c.Node().(type) {
 			case *ast.FuncDecl, *ast.FuncLit:
 				return false
+			case *ast.DeferStmt:
+				// defer in yield func is treated as yield,
+				// which must be rewritten to seq.Defer instead of native defer
+				contains = true
+				panic(abort)
 			case *ast.CallExpr:
-				callee := pkg.Callee(n)
-				if callee == r.yieldFunc || callee == r.yieldFromFunc {
+				if r.isYieldCallee(pkg.Callee(n)) {
 					contains = true
 					panic(abort)
 				}
@@ -107,20 +244,11 @@ func (r *rewriter) containsYield(pkg loader.Pkg, n *ast.BlockStmt) bool {
 	}()
 }
 
-func (r *rewriter) assert(pkg loader.Pkg, ok bool, pos any, format string, a ...any) {
+// assert panics with the diagnostic at pos, which is ast.Node or token.Pos,
+// and recovered by catch of the yield func
+func (r *rewriter) assert(pkg loader.Pkg, ok bool, pos any, code Code, format string, a ...any) {
 	if !ok {
-		loc := "unknown"
-		if !isNil(pos) {
-			switch pos := pos.(type) {
-			case ast.Node:
-				loc = pkg.ShowNode(pos)
-			case token.Pos:
-				loc = pkg.Fset.Position(pos).String()
-			case string:
-				loc = pos
-			}
-		}
-		panic(fmt.Sprintf(format, a...) + " in: " + loc)
+		panic(mkDiagnostic(pkg, pos, code, format, a...))
 	}
 }
 
@@ -129,13 +257,14 @@ func (r *rewriter) assert(pkg loader.Pkg, ok bool, pos any, format string, a ...
 func (r *rewriter) rewriteAllFiles(printer FilePrinter) {
 	coPkg := r.m.Loader.LookupPackage(pkgCoPath)
 	if coPkg == nil {
-		log.Printf("skip rewrite: no import %s\n", pkgCoPath)
+		r.logf("skip rewrite: no import %s\n", pkgCoPath)
 		return
 	}
 
+	r.diags = nil
 	r.m.Loader.VisitAllFiles(func(f *loader.File) {
 		if !imports.Uses(f, coPkg.Types) {
-			log.Printf("skip file: %s\n", f.Filename)
+			r.logf("skip file: %s\n", f.Filename)
 			return
 		}
 		r.rewriteFile(f, printer)
@@ -155,17 +284,25 @@ func (r *rewriter) rewriteFile(f *loader.File, printer FilePrinter) {
 	}
 
 	pkg := f.Package()
+	diags := len(r.diags)
 
 	// 1. init context
+	r.file = f.File
 	r.coImportedName, r.seqImportedName = parseOrImport(f.Pkg.Fset, f.File) // parse import name
 	r.comments = nil
+	r.symCnt = 0
+	r.stmtPos = map[ast.Stmt]token.Pos{}
+	r.fileComment = fileCommentOf(f.File, r.buildTag) // before comments cleared
 
 	r.yieldFuncDecls = map[*ast.FuncDecl]bool{}
 	r.yieldFuncLits = map[*ast.FuncLit]bool{}
 	r.collectYieldFunc(pkg, f) // collect func with yield/yieldFrom call
+	r.checkIterChan(pkg, f)
+	r.checkBreakLabel(pkg, f)
+	r.checkReturn(pkg, f)
 
 	// 2. edit file
-	log.Printf("visit file: %s\n", f.Filename)
+	r.logf("visit file: %s\n", f.Filename)
 	do := func(fn func(*astutil.Cursor, loader.Pkg) bool) {
 		astutil.Apply(f.File, nil, func(c *astutil.Cursor) bool {
 			return fn(c, pkg)
@@ -174,14 +311,17 @@ func (r *rewriter) rewriteFile(f *loader.File, printer FilePrinter) {
 
 	// file level instance for file scope cache
 	// notice: order matters
-	do(r.attachComment)             // attach the original source to comments
-	do(mkYieldFromRewriter(r, pkg)) // rewrite yieldFrom() to range yield() (range co.Iter)
-	do(r.rewriteForRanges)          // rewrite range co.Iter to for loop co.Iter
-	do(mkYieldRewriter(r, pkg))     // rewrite yield func
-	do(r.rewriteIter)               // rewrite all co.Iter to seq.Iterator
+	do(r.attachComment)         // attach the original source to comments
+	do(r.rewriteForRanges)      // rewrite range co.Iter to for loop co.Iter
+	do(mkYieldRewriter(r, pkg)) // rewrite yield func
+	do(r.rewriteIter)           // rewrite all co.Iter to seq.Iterator
 
 	// 3. write file
-	log.Printf("write file: %s\n", f.Filename)
+	if len(r.diags) > diags {
+		r.logf("skip file with errors: %s\n", f.Filename)
+		return
+	}
+	r.logf("write file: %s\n", f.Filename)
 	// clear free-floating comments, preventing confusing position of comments
 	// https://github.com/golang/go/issues/20744
 	f.File.Comments = r.comments
@@ -200,19 +340,28 @@ func (r *rewriter) collectYieldFunc(pkg loader.Pkg, f *loader.File) {
 		outer         = yieldFunStack.top
 	)
 
-	checkSignature := func(funTy types.Type, pos token.Pos) {
-		msg := "invalid yield func signature, expect one co.Iter[T] return"
+	// the invalid yield func is reported once, and not rewritten
+	checked := map[ast.Node]bool{}
+	checkSignature := func(f ast.Node, funTy types.Type, pos token.Pos) bool {
+		if ok, seen := checked[f]; seen {
+			return ok
+		}
+		checked[f] = r.catch(func() {
+			msg := "invalid yield func signature, " +
+				"expect one co.Iter[T], co.Generator[T, R], co.Coroutine[T, S, R] or iter.Seq[T] return"
 
-		sig, ok := funTy.(*types.Signature)
-		r.assert(pkg, ok, pos, msg)
-		rs := sig.Results()
+			sig, ok := funTy.(*types.Signature)
+			r.assert(pkg, ok, pos, CodeYieldFuncSignature, msg)
+			rs := sig.Results()
 
-		singleRet := rs != nil && rs.Len() == 1
-		r.assert(pkg, singleRet, pos, msg)
+			singleRet := rs != nil && rs.Len() == 1
+			r.assert(pkg, singleRet, pos, CodeYieldFuncSignature, msg)
 
-		retTy := rs.At(0).Type()
-		retIter := r.isIterator(retTy)
-		r.assert(pkg, retIter, pos, msg)
+			retTy := rs.At(0).Type()
+			retIter := r.isIterator(retTy) || r.isStdSeq(retTy)
+			r.assert(pkg, retIter, pos, CodeYieldFuncSignature, msg)
+		})
+		return checked[f]
 	}
 
 	info := f.Pkg.TypesInfo
@@ -239,17 +388,147 @@ func (r *rewriter) collectYieldFunc(pkg loader.Pkg, f *loader.File) {
 			exit()
 
 		case *ast.CallExpr:
-			callee := typeutil.Callee(info, n)
-			if callee == r.yieldFunc || callee == r.yieldFromFunc {
+			if r.isYieldCallee(typeutil.Callee(info, n)) {
 				switch f := outer().(type) {
 				case *ast.FuncDecl:
-					checkSignature(info.TypeOf(f.Name), n.Pos())
-					r.yieldFuncDecls[f] = true
+					if checkSignature(f, info.TypeOf(f.Name), n.Pos()) {
+						r.yieldFuncDecls[f] = true
+					}
 				case *ast.FuncLit:
-					checkSignature(info.TypeOf(f), n.Pos())
-					r.yieldFuncLits[f] = true
+					if checkSignature(f, info.TypeOf(f), n.Pos()) {
+						r.yieldFuncLits[f] = true
+					}
+				}
+			}
+		}
+		return true
+	})
+}
+
+// the underlying chan of co.Iter is for type checking only, which is rewritten to seq.Iterator,
+// so receiving, len, cap, close and the two-variable range are reported, instead of failing to compile the generated code
+func (r *rewriter) checkIterChan(pkg loader.Pkg, f *loader.File) {
+	msg := "%s on iterator %s, which is not a real chan, use MoveNext / Current / Close instead"
+	ast.Inspect(f.File, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.RangeStmt:
+			// not well typed, the chan permits only one iteration variable
+			if n.Value != nil && r.isIterator(pkg.TypeOf(n.X)) && !r.isIter2(pkg.TypeOf(n.X)) {
+				r.catch(func() {
+					r.assert(pkg, false, n.Key, CodeIterChan,
+						"two-variable range over iterator %s, which is not a real chan, range with one variable and count the index instead",
+						pkg.ShowNode(n.X))
+				})
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW && r.isIterator(pkg.TypeOf(n.X)) {
+				r.catch(func() {
+					r.assert(pkg, false, n, CodeIterChan, msg, "receive", pkg.ShowNode(n.X))
+				})
+			}
+		case *ast.CallExpr:
+			b, ok := pkg.Callee(n).(*types.Builtin)
+			if !ok || len(n.Args) != 1 {
+				return true
+			}
+			switch b.Name() {
+			case "len", "cap", "close":
+				if r.isIterator(pkg.TypeOf(n.Args[0])) {
+					r.catch(func() {
+						r.assert(pkg, false, n, CodeIterChan, msg, b.Name(), pkg.ShowNode(n.Args[0]))
+					})
+				}
+			}
+		}
+		return true
+	})
+}
+
+// break targeting the labeled stmt other than for, switch and select is invalid,
+// which is reported instead of completing the labeled stmt by seq.Labeled
+func (r *rewriter) checkBreakLabel(pkg loader.Pkg, f *loader.File) {
+	ast.Inspect(f.File, func(n ast.Node) bool {
+		l, ok := n.(*ast.LabeledStmt)
+		if !ok {
+			return true
+		}
+		switch l.Stmt.(type) {
+		case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			return true
+		}
+		ast.Inspect(l.Stmt, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.BranchStmt:
+				if n.Tok == token.BREAK && n.Label != nil && n.Label.Name == l.Label.Name {
+					r.catch(func() {
+						r.assert(pkg, false, n, CodeBreakLabel,
+							"invalid break label %s, which is not for, switch or select", n.Label.Name)
+					})
+				}
+			}
+			return true
+		})
+		return true
+	})
+}
+
+// co.Return[Y](r) is unwrapped to r by rewriting the return stmt of the yield func,
+// so it is reported if used in the other forms, or r is not assignable to the result,
+// i.e., R of co.Generator[Y, R] and co.Coroutine[Y, S, R], or error of co.ErrIter[Y]
+func (r *rewriter) checkReturn(pkg loader.Pkg, f *loader.File) {
+	returned := map[*ast.CallExpr]bool{}
+	checkYieldFunc := func(funTy *ast.FuncType, body *ast.BlockStmt) {
+		ty := pkg.TypeOf(funTy.Results.List[0].Type)
+		var want types.Type
+		switch {
+		case r.isGenerator(ty):
+			want = ty.(*types.Named).TypeArgs().At(1)
+		case r.isCoroutine(ty):
+			want = ty.(*types.Named).TypeArgs().At(2)
+		case r.isErrIter(ty):
+			want = types.Universe.Lookup("error").Type()
+		}
+		ast.Inspect(body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false // checked if yield func
+			case *ast.ReturnStmt:
+				if len(n.Results) != 1 {
+					return true
+				}
+				call, ok := r.isCallOf(pkg, n.Results[0], r.returnFunc)
+				if !ok || want == nil {
+					return true
 				}
+				returned[call] = true
+				got := pkg.TypeOf(call.Args[0])
+				if got == nil || got == types.Typ[types.Invalid] {
+					return true // reported by type checker
+				}
+				r.catch(func() {
+					arg := pkg.ShowNode(call.Args[0])
+					r.assert(pkg, types.AssignableTo(got, want), call.Args[0], CodeReturn,
+						"return type mismatch, typeof(%s) is %s, not assignable to %s", arg, got, want)
+				})
 			}
+			return true
+		})
+	}
+	for fun := range r.yieldFuncDecls {
+		checkYieldFunc(fun.Type, fun.Body)
+	}
+	for fun := range r.yieldFuncLits {
+		checkYieldFunc(fun.Type, fun.Body)
+	}
+
+	ast.Inspect(f.File, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok && pkg.Callee(call) == r.returnFunc && !returned[call] {
+			r.catch(func() {
+				r.assert(pkg, false, call, CodeReturn, "invalid Return, only supported in the form of "+
+					"`return Return[Y](r)` in yield func returning co.Generator, co.Coroutine or co.ErrIter")
+			})
 		}
 		return true
 	})
@@ -288,8 +567,13 @@ func (r *rewriter) attachComment(c *astutil.Cursor, pkg loader.Pkg) bool {
 func (r *rewriter) rewriteForRanges(c *astutil.Cursor, pkg loader.Pkg) bool {
 	switch n := c.Node().(type) {
 	case *ast.RangeStmt:
-		if r.isIterator(pkg.TypeOf(n.X)) {
-			c.Replace(r.rewriteForRange(pkg, n))
+		// the two-variable form over co.Iter is reported by checkIterChan
+		if ty := pkg.TypeOf(n.X); r.isIterator(ty) && (n.Value == nil || r.isIter2(ty)) {
+			var label *ast.Ident
+			if l, ok := c.Parent().(*ast.LabeledStmt); ok {
+				label = l.Label
+			}
+			c.Replace(r.rewriteForRange(pkg, n, label))
 		}
 		return true
 	}
@@ -306,27 +590,152 @@ func (r *rewriter) rewriteForRanges(c *astutil.Cursor, pkg loader.Pkg) bool {
 //		x [:]= it.Current()
 //		$body
 //	}
-func (r *rewriter) rewriteForRange(pkg loader.Pkg, fr *ast.RangeStmt) *ast.ForStmt {
-	isValid := fr.Key != nil && fr.Value == nil
-	r.assert(pkg, isValid, fr, "invalid for range")
+//
+// or for co.Iter2
+//
+//	for k, v [:]= range $X { $body }
+//	=>
+//	for it := $X ; it.Next(); {
+//		k, v [:]= it.Current().Key, it.Current().Val
+//		$body
+//	}
+//
+// the iterator is closed before leaving the loop early, e.g.,
+//
+//	for it := $X ; it.Next(); {
+//		x [:]= it.Current()
+//		if $cond {
+//			seq.Close[V](it)
+//			break
+//		}
+//	}
+func (r *rewriter) rewriteForRange(pkg loader.Pkg, fr *ast.RangeStmt, label *ast.Ident) *ast.ForStmt {
+	iter2 := r.isIter2(pkg.TypeOf(fr.X))
 
 	// iter := X.Ident(cstIterVar)
-	iter := pkg.NewIdent(cstIterVar, pkg.TypeOf(fr.X))
+	var iter *ast.Ident
+	r.closeOnEarlyExit(fr.Body, label, func() ast.Stmt {
+		if iter == nil {
+			// unique name, the iterator may be closed in the nested loops
+			r.symCnt++
+			iter = pkg.NewIdent(cstCloseVar+strconv.Itoa(r.symCnt), pkg.TypeOf(fr.X))
+		}
+		return X.Stmt(X.Call(r.seqIterFunc(pkg, cstClose, pkg.TypeOf(fr.X)), iter))
+	})
+	if iter == nil {
+		iter = pkg.NewIdent(cstIterVar, pkg.TypeOf(fr.X))
+	}
 	current := X.Select(iter, cstCurrent)
 	next := X.Select(iter, cstMoveNext)
 
 	init := X.Define(iter, fr.X)
 	cond := X.Call(next)
-	body := X.Block1(
-		X.Assign(fr.Tok, fr.Key, X.Call(current)),
-		fr.Body.List...,
+
+	var key, val ast.Expr
+	if iter2 {
+		key = X.Select(X.Call(current), cstPairKey)
+		val = X.Select(X.Call(current), cstPairVal)
+	} else {
+		key = X.Call(current) // the value of one-variable form
+	}
+
+	var kv ast.Stmt
+	ignoreKey := fr.Key == nil || isUnderline(fr.Key)
+	ignoreVal := fr.Value == nil || isUnderline(fr.Value)
+	switch {
+	case ignoreKey && ignoreVal:
+		return X.ForStmt(init, cond, nil, fr.Body)
+	case ignoreVal:
+		kv = X.Assign(fr.Tok, fr.Key, key)
+	case ignoreKey:
+		kv = X.Assign(fr.Tok, fr.Value, val)
+	default:
+		kv = X.Assign2(fr.Tok, fr.Key, fr.Value, key, val)
+	}
+	return X.ForStmt(init, cond, nil, X.Block1(kv, fr.Body.List...))
+}
+
+// insert close stmt before the stmts leaving the loop body early,
+// i.e., return, or break / continue / goto targeting the outside of the loop
+func (r *rewriter) closeOnEarlyExit(body *ast.BlockStmt, label *ast.Ident, closeStmt func() ast.Stmt) {
+	inner := map[string]bool{} // labels declared in the loop body
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.LabeledStmt:
+			inner[n.Label.Name] = true
+		}
+		return true
+	})
+
+	var (
+		loops    int // nested loops, targets of unlabeled break and continue
+		breakers int // nested switch / select, targets of unlabeled break
 	)
-	return X.ForStmt(init, cond, nil, body)
+	exits := func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ReturnStmt:
+			return true
+		case *ast.BranchStmt:
+			switch n.Tok {
+			case token.BREAK:
+				if n.Label == nil {
+					return loops == 0 && breakers == 0
+				}
+				return !inner[n.Label.Name]
+			case token.CONTINUE:
+				if n.Label == nil {
+					return false
+				}
+				return !inner[n.Label.Name] && (label == nil || n.Label.Name != label.Name)
+			case token.GOTO:
+				return !inner[n.Label.Name]
+			}
+		}
+		return false
+	}
+
+	astutil.Apply(body, func(c *astutil.Cursor) bool {
+		switch n := c.Node().(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ForStmt, *ast.RangeStmt:
+			loops++
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			breakers++
+		case ast.Stmt:
+			if exits(n) {
+				if c.Index() >= 0 {
+					c.InsertBefore(closeStmt())
+				} else {
+					c.Replace(X.Block(closeStmt(), n))
+				}
+				return false
+			}
+		}
+		return true
+	}, func(c *astutil.Cursor) bool {
+		switch c.Node().(type) {
+		case *ast.ForStmt, *ast.RangeStmt:
+			loops--
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+			breakers--
+		}
+		return true
+	})
 }
 
 // ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓ Rewrite co.Iter ↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓↓
 
 // co.Iter[T] => seq.Iterator[T]
+// co.ErrIter[T] => seq.Iterator[T]
+// co.Iter2[K, V] => seq.Iterator[seq.Pair[K, V]]
+// co.Generator[T, R] => seq.ResultIterator[T, R]
+// co.Coroutine[T, S, R] => seq.Generator[T, S, R]
+// it.Send(v) => seq.Send[V](it, v)
+// it.Close() => seq.Close[V](it)
+// it.Catch() => seq.Catch[I, V](it)
 func (r *rewriter) rewriteIter(c *astutil.Cursor, pkg loader.Pkg) bool {
 	switch n := c.Node().(type) {
 	case *ast.IndexExpr:
@@ -337,6 +746,70 @@ func (r *rewriter) rewriteIter(c *astutil.Cursor, pkg loader.Pkg) bool {
 			))
 		}
 		return true
+	case *ast.IndexListExpr:
+		switch ty := pkg.TypeOf(n.X); {
+		case r.isIter2(ty):
+			c.Replace(X.Index(
+				X.PkgSelect(r.seqImportedName, cstIterator),
+				r.pairType(n.Indices...),
+			))
+		case r.isGenerator(ty):
+			c.Replace(X.Indices(
+				X.PkgSelect(r.seqImportedName, cstResultIterator),
+				n.Indices...,
+			))
+		case r.isCoroutine(ty):
+			c.Replace(X.Indices(
+				X.PkgSelect(r.seqImportedName, cstGenerator),
+				n.Indices...,
+			))
+		}
+		return true
+	case *ast.CallExpr:
+		sel, ok := n.Fun.(*ast.SelectorExpr)
+		if !ok || !r.isIterator(pkg.TypeOf(sel.X)) {
+			return true
+		}
+		switch sel.Sel.Name {
+		case cstSend, cstClose:
+			// seq.Iterator has no Send / Close method, seq.Generator has
+			if r.isCoroutine(pkg.TypeOf(sel.X)) {
+				return true
+			}
+		case cstCatch:
+		default:
+			return true
+		}
+		c.Replace(X.Call(
+			r.seqIterFunc(pkg, sel.Sel.Name, pkg.TypeOf(sel.X)),
+			append([]ast.Expr{sel.X}, n.Args...)...,
+		))
+		return true
 	}
 	return true
 }
+
+// seq.Send[V] / seq.Close[V] / seq.Catch[I, V] called on the iterator of type it,
+// the type arguments can't be inferred from seq.ResultIterator or seq.Generator before go1.21,
+// they are left to the inference if inexpressible, e.g., the local type
+func (r *rewriter) seqIterFunc(pkg loader.Pkg, name string, it types.Type) (fun ast.Expr) {
+	fun = X.PkgSelect(r.seqImportedName, name)
+	defer func() {
+		if p := recover(); p != nil {
+			if _, ok := p.(fallback); !ok {
+				panic(p)
+			}
+		}
+	}()
+
+	y := &yieldRewriter{rewriter: r, pkg: pkg, yieldAst: &yieldAst{seqImportedName: r.seqImportedName}}
+	args := unalias(it).(*types.Named).TypeArgs()
+	v := y.typeExpr(args.At(0))
+	if r.isIter2(it) {
+		v = r.pairType(v, y.typeExpr(args.At(1)))
+	}
+	if name == cstCatch {
+		return X.Indices(fun, y.typeExpr(it), v)
+	}
+	return X.Index(fun, v)
+}
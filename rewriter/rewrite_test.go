@@ -14,34 +14,51 @@ func TestRewrite(t *testing.T) {
 	out := "test/out"
 	tmp := out + "_tmp"
 
-	Compile(in, out, loader.WithLoadTest())
+	if err := Compile(in, out, WithLoaderOptions(loader.WithLoadTest())); err != nil {
+		t.Fatal(err)
+	}
+
+	assertGolden(t, in, tmp, ".go.tmp")
+	assertGolden(t, in, out, ".go.out")
+}
+
+func TestRewriteMachine(t *testing.T) {
+	in := "test/src"
+	out := "test/out_sm"
+
+	if err := Compile(in, out, WithStateMachine(), WithLoaderOptions(loader.WithLoadTest())); err != nil {
+		t.Fatal(err)
+	}
 
+	assertGolden(t, in, out, ".go.sm")
+}
+
+func TestRewriteMachineInvalidType(t *testing.T) {
+	// the var of invalid type can't be hoisted, which falls back to the monadic rewriting
+	out := t.TempDir()
+	err := GoGen("testdata/invalid", WithOutputDir(out), WithStateMachine(),
+		WithLoaderOptions(loader.WithSuppressErrors()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, _ := os.ReadFile(path.Join(out, "invalid.go"))
+	assertEqual(t, strings.Contains(string(src), "StateMachine"), false)
+	assertEqual(t, strings.Contains(string(src), "Delay"), true)
+}
+
+func assertGolden(t *testing.T, in, out, suffix string) {
 	xs, _ := os.ReadDir(in)
 	for _, x := range xs {
-		if x.IsDir() {
+		if x.IsDir() || !strings.HasSuffix(x.Name(), suffix) {
 			continue
 		}
-
-		if strings.HasSuffix(x.Name(), ".go.tmp") {
-			expect, _ := os.ReadFile(path.Join(in, x.Name()))
-			output, err := os.ReadFile(path.Join(tmp, strings.Split(x.Name(), ".")[0]+".go"))
-			if err != nil {
-				panic(err)
-			}
-			if string(output) != string(expect) {
-				t.Fatalf(x.Name())
-			}
+		expect, _ := os.ReadFile(path.Join(in, x.Name()))
+		output, err := os.ReadFile(path.Join(out, strings.Split(x.Name(), ".")[0]+".go"))
+		if err != nil {
+			panic(err)
 		}
-
-		if strings.HasSuffix(x.Name(), ".go.out") {
-			expect, _ := os.ReadFile(path.Join(in, x.Name()))
-			output, err := os.ReadFile(path.Join(out, strings.Split(x.Name(), ".")[0]+".go"))
-			if err != nil {
-				panic(err)
-			}
-			if string(output) != string(expect) {
-				t.Fatalf(x.Name())
-			}
+		if string(output) != string(expect) {
+			t.Fatalf(x.Name())
 		}
 	}
 }
@@ -0,0 +1,111 @@
+package src
+
+import (
+	"testing"
+
+	. "github.com/goghcrow/go-co"
+)
+
+// the benchmarks of the generated code, compared between test/out and test/out_sm, e.g.,
+// go test -run none -bench . ./rewriter/test/out ./rewriter/test/out_sm
+
+func counting(n int) Iter[int] {
+	for i := 0; i < n; i++ {
+		Yield(i)
+	}
+	return nil
+}
+
+func skipping(n, skip int) Iter[int] {
+	for i := 0; i < n; i++ {
+		if i%skip != 0 {
+			continue
+		}
+		Yield(i)
+	}
+	return nil
+}
+
+func summing() Iter[int] {
+	sum := 0
+	for {
+		v := Yield(sum)
+		sum += v
+	}
+}
+
+func delegating(n int) Iter[int] {
+	YieldFrom(counting(n))
+	return nil
+}
+
+func drain[V any](it Iter[V]) {
+	for it.MoveNext() {
+	}
+}
+
+// the hand-written equivalent of counting
+type counter struct {
+	i, n int
+}
+
+func (c *counter) MoveNext() bool {
+	c.i++
+	return c.i <= c.n
+}
+
+func (c *counter) Current() int { return c.i - 1 }
+
+// the baseline of BenchmarkYield
+func BenchmarkHandWritten(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c := &counter{n: 1000}
+		for c.MoveNext() {
+		}
+	}
+}
+
+// yielding every iteration
+func BenchmarkYield(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		drain(counting(1000))
+	}
+}
+
+// resumed by Send, the yield expression receives the sent value
+func BenchmarkYieldRecv(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		it := summing()
+		for j := 0; j < 1000; j++ {
+			it.Send(j)
+		}
+		it.Close()
+	}
+}
+
+// yield n items delegated by YieldFrom
+func BenchmarkYieldFrom(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		drain(delegating(1000))
+	}
+}
+
+// non-yielding iterations
+func BenchmarkSkipping(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		drain(skipping(1000, 1000))
+	}
+}
+
+// the stack would grow with the non-yielding iterations if not trampolined
+func BenchmarkSkippingMany(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		drain(skipping(1_000_000, 1_000_000))
+	}
+}
@@ -0,0 +1,82 @@
+package src
+
+import (
+	"testing"
+
+	. "github.com/goghcrow/go-co"
+)
+
+func TestCloseOnBreak(t *testing.T) {
+	var log []string
+	g := func(name string) Iter[int] {
+		defer func() {
+			log = append(log, "close "+name)
+		}()
+		for i := 0; ; i++ {
+			Yield(i)
+		}
+	}
+
+	for i := range g("a") {
+		if i == 2 {
+			break
+		}
+	}
+	assertEqual(t, log, []string{"close a"})
+
+	log = nil
+	first := func() int {
+		for i := range g("b") {
+			return i
+		}
+		return -1
+	}
+	assertEqual(t, first(), 0)
+	assertEqual(t, log, []string{"close b"})
+
+	log = nil
+outer:
+	for i := range g("c") {
+		for j := range g("d") {
+			if i+j == 3 {
+				break outer
+			}
+			if j == i {
+				continue outer
+			}
+		}
+	}
+	assertEqual(t, log, []string{"close d", "close d", "close d", "close c"})
+
+	log = nil
+	it := g("e")
+	it.MoveNext()
+	assertEqual(t, it.Close(), nil)
+	assertEqual(t, it.MoveNext(), false)
+	assertEqual(t, log, []string{"close e"})
+}
+
+func TestCloseInYieldFunc(t *testing.T) {
+	var log []string
+	naturals := func() Iter[int] {
+		defer func() {
+			log = append(log, "close naturals")
+		}()
+		for i := 0; ; i++ {
+			Yield(i)
+		}
+	}
+	take := func(it Iter[int], n int) Iter[int] {
+		for x := range it {
+			if n == 0 {
+				return nil
+			}
+			n--
+			Yield(x)
+		}
+		return nil
+	}
+
+	assertEqual(t, iter2slice(take(naturals(), 3)), []int{0, 1, 2})
+	assertEqual(t, log, []string{"close naturals"})
+}
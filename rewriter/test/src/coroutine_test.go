@@ -0,0 +1,76 @@
+package src
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/goghcrow/go-co"
+)
+
+type request struct {
+	key string
+}
+
+type response struct {
+	val string
+	ok  bool
+}
+
+func TestCoroutine(t *testing.T) {
+	// yield requests, receive responses, return the joined values
+	g := func(keys ...string) Coroutine[request, response, string] {
+		var vals []string
+		for _, k := range keys {
+			resp := YieldRecv[response](request{k})
+			if !resp.ok {
+				return Return[request]("missing " + k)
+			}
+			vals = append(vals, resp.val)
+		}
+		return Return[request](strings.Join(vals, ","))
+	}
+
+	serve := func(co Coroutine[request, response, string], db map[string]string) string {
+		if !co.MoveNext() {
+			return co.Result()
+		}
+		for {
+			v, ok := db[co.Current().key]
+			if _, more := co.Send(response{v, ok}); !more {
+				return co.Result()
+			}
+		}
+	}
+
+	db := map[string]string{"a": "1", "b": "2"}
+	assertEqual(t, serve(g("a", "b"), db), "1,2")
+	assertEqual(t, serve(g("a", "c", "b"), db), "missing c")
+	assertEqual(t, serve(g(), db), "")
+}
+
+func TestCoroutineAssign(t *testing.T) {
+	g := func() Coroutine[int, string, int] {
+		var s string
+		n := 0
+		for i := 0; i < 3; i++ {
+			s = YieldRecv[string](i)
+			n += len(s)
+		}
+		return Return[int](n)
+	}
+
+	it := g()
+	var xs []int
+	for it.MoveNext() {
+		xs = append(xs, it.Current())
+	}
+	assertEqual(t, xs, []int{0, 1, 2})
+	assertEqual(t, it.Result(), 0)
+
+	it = g()
+	it.Send("ab")
+	it.Send("c")
+	_, ok := it.Send("def")
+	assertEqual(t, ok, false)
+	assertEqual(t, it.Result(), 6)
+}
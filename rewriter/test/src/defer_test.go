@@ -0,0 +1,89 @@
+package src
+
+import (
+	"testing"
+
+	. "github.com/goghcrow/go-co"
+)
+
+func TestDefer(t *testing.T) {
+	var log []int
+	g := func() Iter[int] {
+		defer func() { log = append(log, 0) }()
+		for i := 1; i <= 3; i++ {
+			defer func(i int) { log = append(log, i) }(i)
+			Yield(i)
+		}
+		return nil
+	}
+	xs := iter2slice(g())
+	assertEqual(t, xs, []int{1, 2, 3})
+	assertEqual(t, log, []int{3, 2, 1, 0})
+}
+
+func TestDeferEvalArgs(t *testing.T) {
+	var log []int
+	add := func(x int) { log = append(log, x) }
+	g := func() Iter[int] {
+		x := 1
+		f := add
+		defer f(x)
+		f = func(int) { panic("unreachable") }
+		x = 2
+		Yield(x)
+		defer add(42)
+		return nil
+	}
+	xs := iter2slice(g())
+	assertEqual(t, xs, []int{2})
+	assertEqual(t, log, []int{42, 1})
+}
+
+func TestDeferReturn(t *testing.T) {
+	var log []int
+	g := func(n int) Iter[int] {
+		defer func() { log = append(log, -1) }()
+		for i := 0; ; i++ {
+			if i == n {
+				return nil
+			}
+			Yield(i)
+		}
+	}
+	xs := iter2slice(g(2))
+	assertEqual(t, xs, []int{0, 1})
+	assertEqual(t, log, []int{-1})
+}
+
+func TestDeferClose(t *testing.T) {
+	var log []int
+	g := func() Iter[int] {
+		defer func() { log = append(log, -1) }()
+		for i := 0; ; i++ {
+			Yield(i)
+		}
+	}
+	it := g()
+	it.MoveNext()
+	it.MoveNext()
+	assertEqual(t, it.Current(), 1)
+	assertEqual(t, log, []int(nil))
+	if c, ok := any(it).(interface{ Close() error }); ok {
+		_ = c.Close()
+	}
+	assertEqual(t, log, []int{-1})
+}
+
+func TestDeferRecover(t *testing.T) {
+	var recovered any
+	g := func() Iter[int] {
+		defer func() {
+			recovered = recover()
+		}()
+		Yield(1)
+		panic("boom")
+	}
+	xs := iter2slice(g())
+	assertEqual(t, xs, []int{1})
+	assertEqual(t, recovered, "boom")
+}
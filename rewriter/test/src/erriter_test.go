@@ -0,0 +1,73 @@
+package src
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	. "github.com/goghcrow/go-co"
+)
+
+func TestErrIter(t *testing.T) {
+	atoi := func(xs []string) ErrIter[int] {
+		for _, x := range xs {
+			n, err := strconv.Atoi(x)
+			if err != nil {
+				return Return[int](err)
+			}
+			Yield(n)
+		}
+		return nil
+	}
+
+	it := atoi([]string{"1", "2", "x", "3"})
+	var ns []int
+	for n := range it {
+		ns = append(ns, n)
+	}
+	assertEqual(t, ns, []int{1, 2})
+	var numErr *strconv.NumError
+	assertEqual(t, errors.As(it.Err(), &numErr), true)
+	assertEqual(t, numErr.Num, "x")
+
+	it = atoi([]string{"1", "2"})
+	ns = nil
+	for n := range it {
+		ns = append(ns, n)
+	}
+	assertEqual(t, ns, []int{1, 2})
+	assertEqual(t, it.Err(), nil)
+}
+
+func TestErrIterPropagation(t *testing.T) {
+	errNeg := errors.New("negative")
+	check := func(xs []int) ErrIter[int] {
+		for _, x := range xs {
+			if x < 0 {
+				return Return[int](errNeg)
+			}
+			Yield(x)
+		}
+		return nil
+	}
+	double := func(xs []int) ErrIter[int] {
+		it := check(xs)
+		for x := range it {
+			Yield(x * 2)
+		}
+		return Return[int](it.Err())
+	}
+
+	it := double([]int{1, 2, -3, 4})
+	var xs []int
+	for it.MoveNext() {
+		xs = append(xs, it.Current())
+	}
+	assertEqual(t, xs, []int{2, 4})
+	assertEqual(t, it.Err(), errNeg)
+
+	it = double([]int{1})
+	it.MoveNext()
+	assertEqual(t, it.MoveNext(), false)
+	assertEqual(t, it.Err(), nil)
+}
@@ -0,0 +1,81 @@
+package src
+
+import (
+	"strconv"
+	"testing"
+
+	. "github.com/goghcrow/go-co"
+)
+
+func TestGeneratorResult(t *testing.T) {
+	g := func(n int) Generator[int, string] {
+		sum := 0
+		for i := 0; i < n; i++ {
+			Yield(i)
+			sum += i
+		}
+		if sum == 0 {
+			return Return[int]("empty")
+		}
+		return Return[int]("sum=" + strconv.Itoa(sum))
+	}
+
+	it := g(4)
+	var xs []int
+	for it.MoveNext() {
+		xs = append(xs, it.Current())
+	}
+	assertEqual(t, xs, []int{0, 1, 2, 3})
+	assertEqual(t, it.Result(), "sum=6")
+	assertEqual(t, g(0).Result(), "")
+
+	it = g(0)
+	for it.MoveNext() {
+	}
+	assertEqual(t, it.Result(), "empty")
+}
+
+func TestGeneratorReturnNil(t *testing.T) {
+	g := func(fail bool) Generator[string, error] {
+		Yield("a")
+		if fail {
+			return Return[string](strconv.ErrSyntax)
+		}
+		Yield("b")
+		return nil
+	}
+
+	it := g(false)
+	for it.MoveNext() {
+	}
+	assertEqual(t, it.Result(), nil)
+
+	it = g(true)
+	var xs []string
+	for x := range it {
+		xs = append(xs, x)
+	}
+	assertEqual(t, xs, []string{"a"})
+	assertEqual(t, it.Result(), strconv.ErrSyntax)
+}
+
+func TestGeneratorSend(t *testing.T) {
+	// the result is the count of the received values
+	g := func() Generator[int, int] {
+		n := 0
+		for {
+			v := Yield(n)
+			if v < 0 {
+				return Return[int](n)
+			}
+			n++
+		}
+	}
+
+	it := g()
+	it.Send(1)
+	it.Send(1)
+	_, ok := it.Send(-1)
+	assertEqual(t, ok, false)
+	assertEqual(t, it.Result(), 2)
+}
@@ -0,0 +1,184 @@
+package src
+
+import (
+	"testing"
+
+	. "github.com/goghcrow/go-co"
+)
+
+func TestGotoBackward(t *testing.T) {
+	g := func(n int) Iter[int] {
+		i := 0
+	loop:
+		Yield(i)
+		i++
+		if i < n {
+			goto loop
+		}
+		return nil
+	}
+	assertEqual(t, iter2slice(g(3)), []int{0, 1, 2})
+	assertEqual(t, iter2slice(g(0)), []int{0})
+}
+
+func TestGotoForward(t *testing.T) {
+	g := func(skip bool) Iter[int] {
+		Yield(1)
+		if skip {
+			goto end
+		}
+		Yield(2)
+	end:
+		Yield(3)
+		return nil
+	}
+	assertEqual(t, iter2slice(g(false)), []int{1, 2, 3})
+	assertEqual(t, iter2slice(g(true)), []int{1, 3})
+}
+
+func TestGotoStateMachine(t *testing.T) {
+	g := func(s string) Iter[string] {
+		i := 0
+	start:
+		if i == len(s) {
+			goto done
+		}
+		if s[i] >= '0' && s[i] <= '9' {
+			goto number
+		}
+		i++
+		goto start
+	number:
+		{
+			j := i
+			for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+				i++
+			}
+			Yield(s[j:i])
+		}
+		goto start
+	done:
+		Yield("EOF")
+		return nil
+	}
+	assertEqual(t, iter2slice(g("a12b3cc456")), []string{"12", "3", "456", "EOF"})
+	assertEqual(t, iter2slice(g("")), []string{"EOF"})
+}
+
+func TestGotoOutOfLoop(t *testing.T) {
+	g := func() Iter[int] {
+		for i := 0; ; i++ {
+			for j := 0; j < 3; j++ {
+				if i*j == 2 {
+					goto out
+				}
+				Yield(i*10 + j)
+			}
+		}
+	out:
+		Yield(-1)
+		return nil
+	}
+	assertEqual(t, iter2slice(g()), []int{0, 1, 2, 10, 11, -1})
+}
+
+func TestGotoLabeledLoop(t *testing.T) {
+	g := func() Iter[int] {
+		n := 0
+	again:
+		for i := 0; i < 3; i++ {
+			if i == 2 {
+				continue again
+			}
+			Yield(n*10 + i)
+		}
+		n++
+		if n < 2 {
+			goto again
+		}
+		return nil
+	}
+	assertEqual(t, iter2slice(g()), []int{0, 1, 10, 11})
+}
+
+func TestTrivalGoto(t *testing.T) {
+	g := func() Iter[int] {
+		i, sum := 0, 0
+	loop:
+		sum += i
+		i++
+		if i <= 3 {
+			goto loop
+		}
+		Yield(sum)
+		return nil
+	}
+	assertEqual(t, iter2slice(g()), []int{6})
+}
+
+func TestGotoHoist(t *testing.T) {
+	g := func(n int) Iter[int] {
+		if n > 2 {
+			goto L
+		}
+		n++
+	L:
+		x := n
+		var acc int
+		const step = 1
+		Yield(x)
+	M:
+		Yield(x + acc)
+		acc += step
+		if x -= step; x > 0 {
+			goto M
+		}
+		return nil
+	}
+	assertEqual(t, iter2slice(g(1)), []int{2, 2, 2})
+	assertEqual(t, iter2slice(g(3)), []int{3, 3, 3, 3})
+}
+
+func TestGotoHoistZero(t *testing.T) {
+	g := func(n int) Iter[int] {
+		i := 0
+	L:
+		var sum int
+		sum += i * 10
+		if sum < 0 {
+			goto M
+		}
+	M:
+		Yield(sum)
+		i++
+		if i < n {
+			goto L
+		}
+		return nil
+	}
+	assertEqual(t, iter2slice(g(3)), []int{0, 10, 20})
+}
+
+func TestGotoHoistShadow(t *testing.T) {
+	g := func(n int) Iter[int] {
+		x := 10
+		for i := 1; i <= n; i++ {
+			k := 0
+		L:
+			Yield(x)
+			x := i*100 + k
+		M:
+			Yield(x)
+			k++
+			if k%2 == 1 {
+				goto M
+			}
+			if k < 4 {
+				goto L
+			}
+		}
+		Yield(x)
+		return nil
+	}
+	assertEqual(t, iter2slice(g(1)), []int{10, 100, 100, 10, 102, 102, 10})
+}
@@ -0,0 +1,83 @@
+//go:build go1.23
+
+package src
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/goghcrow/go-co"
+)
+
+func TestIter2(t *testing.T) {
+	enumerate := func(xs []string) Iter2[int, string] {
+		for i, x := range xs {
+			Yield2(i, strings.ToUpper(x))
+		}
+		return nil
+	}
+
+	var ks []int
+	var vs []string
+	for k, v := range enumerate([]string{"a", "b", "c"}) {
+		ks = append(ks, k)
+		vs = append(vs, v)
+	}
+	assertEqual(t, ks, []int{0, 1, 2})
+	assertEqual(t, vs, []string{"A", "B", "C"})
+
+	ks = nil
+	for k := range enumerate([]string{"a", "b"}) {
+		ks = append(ks, k)
+	}
+	assertEqual(t, ks, []int{0, 1})
+
+	vs = nil
+	for _, v := range enumerate([]string{"a", "b"}) {
+		vs = append(vs, v)
+	}
+	assertEqual(t, vs, []string{"A", "B"})
+
+	n := 0
+	for range enumerate([]string{"a", "b"}) {
+		n++
+	}
+	assertEqual(t, n, 2)
+
+	var k int
+	var v string
+	for k, v = range enumerate([]string{"a", "b"}) {
+		if k == 1 {
+			break
+		}
+	}
+	assertEqual(t, k, 1)
+	assertEqual(t, v, "B")
+
+	it := enumerate([]string{"x"})
+	assertEqual(t, it.MoveNext(), true)
+	assertEqual(t, it.Current().Key, 0)
+	assertEqual(t, it.Current().Val, "X")
+	assertEqual(t, it.MoveNext(), false)
+}
+
+func TestIter2InYieldFunc(t *testing.T) {
+	fib := func(n int) Iter2[int, int] {
+		a, b := 0, 1
+		for i := 0; i < n; i++ {
+			Yield2(i, a)
+			a, b = b, a+b
+		}
+		return nil
+	}
+	// range over Iter2 in yield func
+	evens := func(it Iter2[int, int]) Iter[int] {
+		for i, x := range it {
+			if i%2 == 0 {
+				Yield(x)
+			}
+		}
+		return nil
+	}
+	assertEqual(t, iter2slice(evens(fib(7))), []int{0, 1, 3, 8})
+}
@@ -0,0 +1,104 @@
+//go:build go1.23
+
+package src
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"testing"
+
+	. "github.com/goghcrow/go-co"
+	"github.com/goghcrow/go-co/seq"
+)
+
+func TestYieldStdSeq(t *testing.T) {
+	g := func(n int) iter.Seq[int] {
+		for i := 0; i < n; i++ {
+			Yield(i)
+		}
+		return nil
+	}
+	xs := g(3)
+	assertEqual(t, slices.Collect(xs), []int{0, 1, 2})
+	// restart every time ranged over
+	assertEqual(t, slices.Collect(xs), []int{0, 1, 2})
+}
+
+func TestYieldStdSeqBreak(t *testing.T) {
+	var log []int
+	g := func() iter.Seq[int] {
+		defer func() { log = append(log, -1) }()
+		for i := 0; ; i++ {
+			Yield(i)
+		}
+	}
+	var xs []int
+	for x := range g() {
+		if x == 2 {
+			break
+		}
+		xs = append(xs, x)
+	}
+	assertEqual(t, xs, []int{0, 1})
+	assertEqual(t, log, []int{-1})
+}
+
+func TestToSeq(t *testing.T) {
+	g := func() Iter[int] {
+		Yield(1)
+		Yield(2)
+		return nil
+	}
+	assertEqual(t, slices.Collect(seq.ToSeq[int](g())), []int{1, 2})
+
+	m := map[string]int{"a": 1, "b": 2}
+	assertEqual(t, maps.Collect(seq.ToSeq2(seq.NewMapIter(m))), m)
+}
+
+func TestFromSeq(t *testing.T) {
+	it := seq.FromSeq(slices.Values([]int{1, 2, 3}))
+	var xs []int
+	for it.MoveNext() {
+		xs = append(xs, it.Current())
+	}
+	assertEqual(t, xs, []int{1, 2, 3})
+
+	it2 := seq.FromSeq2(slices.All([]string{"a", "b"}))
+	var ks []int
+	for it2.MoveNext() {
+		ks = append(ks, it2.Current().Key)
+	}
+	assertEqual(t, ks, []int{0, 1})
+}
+
+// the iter.Seq equivalent of counting
+func countingSeq(n int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := 0; i < n; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+// the baseline of BenchmarkYield by iter.Pull
+func BenchmarkPull(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		next, stop := iter.Pull(countingSeq(1000))
+		for _, ok := next(); ok; _, ok = next() {
+		}
+		stop()
+	}
+}
+
+// the generator ranged over by iter.Seq
+func BenchmarkToSeq(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for range seq.ToSeq[int](counting(1000)) {
+		}
+	}
+}
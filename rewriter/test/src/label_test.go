@@ -0,0 +1,150 @@
+package src
+
+import (
+	"testing"
+
+	. "github.com/goghcrow/go-co"
+)
+
+func TestLabeledBreakContinue(t *testing.T) {
+	g := func() Iter[int] {
+	outer:
+		for i := 0; i < 3; i++ {
+			for j := 0; ; j++ {
+				if j == 2 {
+					continue outer
+				}
+				if i == 2 {
+					break outer
+				}
+				Yield(i*10 + j)
+			}
+		}
+		return nil
+	}
+	xs := iter2slice(g())
+	assertEqual(t, xs, []int{0, 1, 10, 11})
+}
+
+func TestLabeledBreakAfterYield(t *testing.T) {
+	g := func() Iter[int] {
+		i := 0
+	outer:
+		for {
+			for {
+				Yield(i)
+				i++
+				if i > 3 {
+					break outer
+				}
+				continue outer
+			}
+		}
+		Yield(42)
+		return nil
+	}
+	xs := iter2slice(g())
+	assertEqual(t, xs, []int{0, 1, 2, 3, 42})
+}
+
+func TestLabeledBreakInTrivalLoop(t *testing.T) {
+	g := func() Iter[int] {
+	outer:
+		for i := 0; i < 3; i++ {
+			Yield(i)
+			for j := 0; j < 3; j++ {
+				if i == 1 {
+					continue outer
+				}
+				if i == 2 {
+					break outer
+				}
+			}
+			Yield(-i)
+		}
+		return nil
+	}
+	xs := iter2slice(g())
+	assertEqual(t, xs, []int{0, 0, 1, 2})
+}
+
+func TestTrivalLabeledLoop(t *testing.T) {
+	g := func() Iter[int] {
+		n := 0
+	outer:
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				if j == 1 {
+					continue outer
+				}
+				n++
+			}
+		}
+		Yield(n)
+		return nil
+	}
+	xs := iter2slice(g())
+	assertEqual(t, xs, []int{3})
+}
+
+func TestLabeledRange(t *testing.T) {
+	g := func() Iter[int] {
+	outer:
+		for _, x := range []int{1, 2, 3} {
+			for _, y := range []int{10, 20, 30} {
+				if y == 30 {
+					continue outer
+				}
+				if x == 3 {
+					break outer
+				}
+				Yield(x + y)
+			}
+		}
+		return nil
+	}
+	xs := iter2slice(g())
+	assertEqual(t, xs, []int{11, 21, 12, 22})
+}
+
+func TestLabeledRanges(t *testing.T) {
+	g := func() Iter[int] {
+	first:
+		for _, x := range []int{1, 2, 3} {
+			if x == 2 {
+				continue first
+			}
+			Yield(x)
+		}
+	second:
+		for _, x := range []int{4, 5, 6} {
+			if x == 5 {
+				break second
+			}
+			Yield(x)
+		}
+		return nil
+	}
+	assertEqual(t, iter2slice(g()), []int{1, 3, 4})
+}
+
+func TestLabeledSwitch(t *testing.T) {
+	g := func(n int) Iter[int] {
+		for i := 0; i < 2; i++ {
+		sw:
+			switch n {
+			case 1:
+				Yield(1)
+				if i == 0 {
+					break sw
+				}
+				Yield(2)
+			default:
+				Yield(0)
+			}
+		}
+		return nil
+	}
+	assertEqual(t, iter2slice(g(1)), []int{1, 1, 2})
+	assertEqual(t, iter2slice(g(0)), []int{0, 0})
+}
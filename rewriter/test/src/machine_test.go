@@ -0,0 +1,90 @@
+package src
+
+import (
+	"testing"
+
+	. "github.com/goghcrow/go-co"
+)
+
+// the cases specific to the state machine, which are also fine with the monadic rewriting
+
+func TestMachineHoistedShadow(t *testing.T) {
+	// the shadowed vars are hoisted with different names
+	g := func() Iter[int] {
+		x := 1
+		Yield(x)
+		{
+			x := x + 1
+			Yield(x)
+			x++
+			Yield(x)
+		}
+		Yield(x)
+		return nil
+	}
+	assertEqual(t, iter2slice(g()), []int{1, 2, 3, 1})
+}
+
+func TestMachineLoopVarReset(t *testing.T) {
+	// the var declared in loop body is reset to zero per iteration
+	g := func() Iter[int] {
+		for i := 0; i < 3; i++ {
+			var sum int
+			sum += i
+			Yield(sum)
+		}
+		return nil
+	}
+	assertEqual(t, iter2slice(g()), []int{0, 1, 2})
+}
+
+func TestMachineLoopVarCaptured(t *testing.T) {
+	// the var captured per iteration can't be hoisted, falls back to monadic rewriting
+	var fs []func() int
+	g := func() Iter[int] {
+		for i := 0; i < 3; i++ {
+			x := i
+			fs = append(fs, func() int { return x })
+			Yield(x)
+		}
+		return nil
+	}
+	assertEqual(t, iter2slice(g()), []int{0, 1, 2})
+	assertEqual(t, fs[0](), 0)
+	assertEqual(t, fs[2](), 2)
+}
+
+func TestMachineResumeInLoop(t *testing.T) {
+	g := func(n int) Iter[int] {
+		sum := 0
+		for i := 0; i < n; i++ {
+			switch {
+			case i%3 == 0:
+				continue
+			case i%3 == 1:
+				v := Yield(i)
+				sum += v
+			default:
+				if i > 6 {
+					break
+				}
+				v := Yield(-i)
+				sum += v
+			}
+		}
+		Yield(sum)
+		return nil
+	}
+
+	it := g(10)
+	var xs []int
+	for {
+		x, ok := it.Send(10)
+		if !ok {
+			break
+		}
+		xs = append(xs, x)
+	}
+	// the first yielded value is skipped by the first Send
+	assertEqual(t, xs, []int{-2, 4, -5, 7, 50})
+}
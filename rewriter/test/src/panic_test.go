@@ -0,0 +1,58 @@
+package src
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/goghcrow/go-co"
+)
+
+func TestPanicReraised(t *testing.T) {
+	var log []string
+	g := func() Iter[int] {
+		defer func() {
+			log = append(log, "deferred")
+		}()
+		Yield(1)
+		panic("boom")
+	}
+
+	it := g()
+	assertEqual(t, it.MoveNext(), true)
+	func() {
+		defer func() {
+			err := recover().(error)
+			assertEqual(t, strings.Contains(err.Error(), "boom"), true)
+			assertEqual(t, strings.Contains(err.Error(), "panic_test.go:"), true)
+		}()
+		it.MoveNext()
+	}()
+	assertEqual(t, log, []string{"deferred"})
+	// finished after panicking
+	assertEqual(t, it.MoveNext(), false)
+	assertEqual(t, it.Err() != nil, true)
+}
+
+func TestPanicCatch(t *testing.T) {
+	g := func(n int) Iter[int] {
+		for i := 0; ; i++ {
+			if i == n {
+				panic("boom")
+			}
+			Yield(i)
+		}
+	}
+
+	it := g(2).Catch()
+	var xs []int
+	for x := range it {
+		xs = append(xs, x)
+	}
+	assertEqual(t, xs, []int{0, 1})
+	assertEqual(t, strings.Contains(it.Err().Error(), "boom"), true)
+
+	it = g(-1).Catch()
+	it.MoveNext()
+	assertEqual(t, it.Close(), nil)
+	assertEqual(t, it.Err(), nil)
+}
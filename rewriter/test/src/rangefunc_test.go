@@ -0,0 +1,156 @@
+//go:build go1.23
+
+package src
+
+import (
+	"iter"
+	"maps"
+	"slices"
+	"testing"
+
+	. "github.com/goghcrow/go-co"
+)
+
+func TestRangeFunc(t *testing.T) {
+	g := func(seq iter.Seq[int]) Iter[int] {
+		for v := range seq {
+			Yield(v)
+			Yield(v * 10)
+		}
+		return nil
+	}
+	assertEqual(t, iter2slice(g(slices.Values([]int{1, 2}))), []int{1, 10, 2, 20})
+}
+
+func TestRangeFunc2(t *testing.T) {
+	g := func(seq iter.Seq2[string, int]) Iter[int] {
+		for k, v := range seq {
+			if k == "b" {
+				continue
+			}
+			Yield(v)
+		}
+		return nil
+	}
+	xs := iter2slice(g(maps.All(map[string]int{"a": 1, "b": 2})))
+	assertEqual(t, xs, []int{1})
+}
+
+func TestRangeFunc0(t *testing.T) {
+	times := func(n int) func(func() bool) {
+		return func(yield func() bool) {
+			for i := 0; i < n; i++ {
+				if !yield() {
+					return
+				}
+			}
+		}
+	}
+	g := func() Iter[int] {
+		for range times(3) {
+			Yield(1)
+		}
+		return nil
+	}
+	assertEqual(t, iter2slice(g()), []int{1, 1, 1})
+}
+
+func TestRangeFuncBreak(t *testing.T) {
+	seq := func(yield func(int) bool) {
+		for i := 0; ; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+	g := func() Iter[int] {
+		for v := range seq {
+			if v == 3 {
+				break
+			}
+			Yield(v)
+		}
+		return nil
+	}
+	assertEqual(t, iter2slice(g()), []int{0, 1, 2})
+}
+
+func TestRangeFuncStop(t *testing.T) {
+	var log []string
+	seq := func(yield func(int) bool) {
+		defer func() { log = append(log, "stopped") }()
+		for i := 0; ; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+
+	brk := func() Iter[int] {
+		for v := range seq {
+			if v == 2 {
+				break
+			}
+			Yield(v)
+		}
+		log = append(log, "after")
+		return nil
+	}
+	assertEqual(t, iter2slice(brk()), []int{0, 1})
+	assertEqual(t, log, []string{"stopped", "after"})
+
+	log = nil
+	ret := func() Iter[int] {
+		for v := range seq {
+			if v == 2 {
+				return nil
+			}
+			Yield(v)
+		}
+		return nil
+	}
+	assertEqual(t, iter2slice(ret()), []int{0, 1})
+	assertEqual(t, log, []string{"stopped"})
+
+	log = nil
+	outer := func() Iter[int] {
+	L:
+		for i := 0; i < 2; i++ {
+			for v := range seq {
+				if v == 1 {
+					continue L
+				}
+				Yield(i*10 + v)
+			}
+		}
+		return nil
+	}
+	assertEqual(t, iter2slice(outer()), []int{0, 10})
+	assertEqual(t, log, []string{"stopped", "stopped"})
+}
+
+func TestRangeFuncClose(t *testing.T) {
+	stopped := false
+	seq := func(yield func(string, int) bool) {
+		defer func() { stopped = true }()
+		for i := 0; ; i++ {
+			if !yield("k", i) {
+				return
+			}
+		}
+	}
+	g := func() Iter[int] {
+		for _, v := range seq {
+			Yield(v)
+		}
+		return nil
+	}
+	it := g()
+	it.MoveNext()
+	it.MoveNext()
+	assertEqual(t, it.Current(), 1)
+	assertEqual(t, stopped, false)
+	it.Close()
+	assertEqual(t, stopped, true)
+	assertEqual(t, it.MoveNext(), false)
+}
@@ -0,0 +1,40 @@
+package src
+
+import (
+	"testing"
+
+	. "github.com/goghcrow/go-co"
+)
+
+func TestRangeIterForms(t *testing.T) {
+	letters := func() Iter[string] {
+		Yield("a")
+		Yield("b")
+		Yield("c")
+		return nil
+	}
+
+	n := 0
+	for range letters() {
+		n++
+	}
+	assertEqual(t, n, 3)
+}
+
+func TestRangeIterFormInYieldFunc(t *testing.T) {
+	letters := func() Iter[string] {
+		Yield("a")
+		Yield("b")
+		Yield("c")
+		return nil
+	}
+	count := func(it Iter[string]) Iter[int] {
+		n := 0
+		for range it {
+			n++
+			Yield(n)
+		}
+		return nil
+	}
+	assertEqual(t, iter2slice(count(letters())), []int{1, 2, 3})
+}
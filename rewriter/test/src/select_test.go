@@ -0,0 +1,83 @@
+package src
+
+import (
+	"testing"
+
+	. "github.com/goghcrow/go-co"
+)
+
+func TestSelect(t *testing.T) {
+	g := func(ch chan int) Iter[int] {
+		select {
+		case v := <-ch:
+			Yield(v)
+			Yield(v + 1)
+		default:
+			Yield(-1)
+		}
+		Yield(42)
+		return nil
+	}
+	{
+		ch := make(chan int, 1)
+		ch <- 1
+		assertEqual(t, iter2slice(g(ch)), []int{1, 2, 42})
+	}
+	{
+		ch := make(chan int, 1)
+		assertEqual(t, iter2slice(g(ch)), []int{-1, 42})
+	}
+}
+
+func TestSelectInLoop(t *testing.T) {
+	g := func(ch chan int, done chan struct{}) Iter[int] {
+		for {
+			select {
+			case v := <-ch:
+				if v == 0 {
+					continue
+				}
+				Yield(v)
+				if v > 2 {
+					break
+				}
+				Yield(-v)
+			case <-done:
+				Yield(0)
+				return nil
+			}
+		}
+	}
+
+	ch := make(chan int, 5)
+	done := make(chan struct{})
+	for _, v := range []int{1, 0, 2, 3} {
+		ch <- v
+	}
+	it := g(ch, done)
+	var xs []int
+	for i := 0; i < 5 && it.MoveNext(); i++ {
+		xs = append(xs, it.Current())
+	}
+	assertEqual(t, xs, []int{1, -1, 2, -2, 3})
+	close(done)
+	xs = append(xs, iter2slice(it)...)
+	assertEqual(t, xs, []int{1, -1, 2, -2, 3, 0})
+}
+
+func TestTrivalSelect(t *testing.T) {
+	g := func(ch chan int) Iter[int] {
+		for i := 0; i < 3; i++ {
+			select {
+			case ch <- i:
+			default:
+				break
+			}
+			Yield(i)
+		}
+		return nil
+	}
+	ch := make(chan int, 3)
+	assertEqual(t, iter2slice(g(ch)), []int{0, 1, 2})
+	assertEqual(t, len(ch), 3)
+}
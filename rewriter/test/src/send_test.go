@@ -0,0 +1,64 @@
+package src
+
+import (
+	"testing"
+
+	. "github.com/goghcrow/go-co"
+)
+
+func TestYieldRecv(t *testing.T) {
+	// running sum of the sent values
+	g := func() Iter[int] {
+		sum := 0
+		for {
+			v := Yield(sum)
+			sum += v
+		}
+	}
+
+	it := g()
+	var xs []int
+	for _, v := range []int{1, 2, 3} {
+		x, ok := it.Send(v)
+		if !ok {
+			t.Fatal("unexpected finished")
+		}
+		xs = append(xs, x)
+	}
+	// the first Send advances to the first yield expression before sending,
+	// so the first yielded value is skipped
+	assertEqual(t, xs, []int{1, 3, 6})
+}
+
+func TestYieldRecvAssign(t *testing.T) {
+	g := func() Iter[string] {
+		var xs [2]string
+		xs[0] = Yield("a")
+		xs[1] = Yield[string]("b")
+		Yield(xs[0] + xs[1])
+		return nil
+	}
+
+	it := g()
+	it.MoveNext()
+	assertEqual(t, it.Current(), "a")
+	x, _ := it.Send("x")
+	assertEqual(t, x, "b")
+	y, _ := it.Send("y")
+	assertEqual(t, y, "xy")
+	_, ok := it.Send("z")
+	assertEqual(t, ok, false)
+}
+
+func TestYieldRecvMoveNext(t *testing.T) {
+	// resumed by MoveNext, the zero value is received
+	g := func() Iter[int] {
+		for i := 0; i < 3; i++ {
+			if v := Yield(i); v != 0 {
+				return nil
+			}
+		}
+		return nil
+	}
+	assertEqual(t, iter2slice(g()), []int{0, 1, 2})
+}
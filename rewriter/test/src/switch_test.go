@@ -147,3 +147,86 @@ func TestSwitch(t *testing.T) {
 	assertEqual(t, iter2slice(g(2, 3)), []string{"2?", "ff"})
 	assertEqual(t, iter2slice(g(3, 1)), []string{"f"})
 }
+
+func TestSwitchFallthrough(t *testing.T) {
+	g := func(a int) (_ Iter[int]) {
+		switch a {
+		case 1:
+			Yield(1)
+			fallthrough
+		case 2:
+			Yield(2)
+			fallthrough
+		case 3:
+			Yield(3)
+		case 4:
+			Yield(4)
+			fallthrough
+		default:
+			Yield(42)
+		}
+		Yield(0)
+		return
+	}
+	assertEqual(t, iter2slice(g(1)), []int{1, 2, 3, 0})
+	assertEqual(t, iter2slice(g(2)), []int{2, 3, 0})
+	assertEqual(t, iter2slice(g(3)), []int{3, 0})
+	assertEqual(t, iter2slice(g(4)), []int{4, 42, 0})
+	assertEqual(t, iter2slice(g(5)), []int{42, 0})
+}
+
+func TestSwitchTrivalFallthrough(t *testing.T) {
+	g := func(a int) (_ Iter[int]) {
+		switch a {
+		case 1:
+			a++
+			fallthrough
+		case 2:
+			Yield(a)
+		}
+		return
+	}
+	assertEqual(t, iter2slice(g(1)), []int{2})
+	assertEqual(t, iter2slice(g(2)), []int{2})
+	assertEqual(t, iter2slice(g(3)), []int(nil))
+}
+
+func TestSwitchBreakAfterYield(t *testing.T) {
+	g := func() (_ Iter[int]) {
+		for i := 0; i < 3; i++ {
+			switch i {
+			case 1:
+				Yield(-1)
+				if i == 1 {
+					break
+				}
+				Yield(-2)
+			default:
+				Yield(i)
+			}
+		}
+		return
+	}
+	assertEqual(t, iter2slice(g()), []int{0, -1, 2})
+}
+
+func TestSwitchTaglessWithYield(t *testing.T) {
+	g := func(xs []int) Iter[string] {
+		for _, x := range xs {
+			switch {
+			case x < 0:
+				Yield("neg")
+			case x == 0:
+				Yield("zero")
+			default:
+				Yield("pos")
+			}
+			switch y := x * 2; {
+			case y > 2:
+				Yield("big")
+			}
+		}
+		return nil
+	}
+	assertEqual(t, iter2slice(g([]int{-1, 0, 2})), []string{"neg", "zero", "pos", "big"})
+}
@@ -153,3 +153,22 @@ func TestTypeSwitchYieldInitAndAssign(t *testing.T) {
 	xs := iter2slice(g())
 	assertEqual(t, xs, []int{0, 42})
 }
+
+func TestTypeSwitchBreakAfterYield(t *testing.T) {
+	g := func(xs ...any) (_ Iter[int]) {
+		for _, x := range xs {
+			switch x := x.(type) {
+			case int:
+				Yield(x)
+				if x < 0 {
+					break
+				}
+				Yield(x * 10)
+			default:
+				Yield(0)
+			}
+		}
+		return
+	}
+	assertEqual(t, iter2slice(g(1, -1, "")), []int{1, 10, -1, 0})
+}
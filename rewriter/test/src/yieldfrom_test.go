@@ -0,0 +1,95 @@
+package src
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/goghcrow/go-co"
+)
+
+func TestYieldFromSend(t *testing.T) {
+	// running sum of the sent values
+	sum := func() Iter[int] {
+		sum := 0
+		for {
+			v := Yield(sum)
+			sum += v
+		}
+	}
+	g := func() Iter[int] {
+		YieldFrom(sum())
+		return nil
+	}
+
+	it := g()
+	var xs []int
+	for _, v := range []int{1, 2, 3} {
+		x, ok := it.Send(v)
+		if !ok {
+			t.Fatal("unexpected finished")
+		}
+		xs = append(xs, x)
+	}
+	// the sent values reach the delegated
+	assertEqual(t, xs, []int{1, 3, 6})
+}
+
+func TestYieldFromClose(t *testing.T) {
+	var log []string
+	inner := func() Iter[int] {
+		defer func() { log = append(log, "inner") }()
+		for {
+			Yield(1)
+		}
+	}
+	outer := func() Iter[int] {
+		defer func() { log = append(log, "outer") }()
+		YieldFrom(inner())
+		return nil
+	}
+
+	it := outer()
+	assertEqual(t, it.MoveNext(), true)
+	assertEqual(t, it.Close(), nil)
+	// the delegated is closed first
+	assertEqual(t, log, []string{"inner", "outer"})
+	assertEqual(t, it.MoveNext(), false)
+}
+
+func TestYieldFromPanic(t *testing.T) {
+	inner := func() Iter[int] {
+		Yield(1)
+		panic("boom")
+	}
+	outer := func(it Iter[int]) Iter[int] {
+		YieldFrom(it)
+		Yield(2)
+		return nil
+	}
+
+	// the panic of the caught delegated is returned as the error of the delegating
+	it := outer(inner().Catch())
+	assertEqual(t, iter2slice(it), []int{1})
+	assertEqual(t, strings.Contains(it.Err().Error(), "boom"), true)
+
+	// the panic is propagated to the delegating
+	it = outer(inner()).Catch()
+	assertEqual(t, iter2slice(it), []int{1})
+	assertEqual(t, strings.Contains(it.Err().Error(), "boom"), true)
+}
+
+func TestYieldFromDeep(t *testing.T) {
+	// each element is yielded by the innermost directly
+	var count func(i, n int) Iter[int]
+	count = func(i, n int) Iter[int] {
+		if i == n {
+			return nil
+		}
+		Yield(i)
+		YieldFrom(count(i+1, n))
+		return nil
+	}
+	xs := iter2slice(count(0, 10000))
+	assertEqual(t, len(xs), 10000)
+	assertEqual(t, xs[9999], 9999)
+}
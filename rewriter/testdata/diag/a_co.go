@@ -0,0 +1,27 @@
+//go:build co
+
+package diag
+
+import (
+	. "github.com/goghcrow/go-co"
+)
+
+func NotIter() int {
+	Yield(1)
+	return 0
+}
+
+func Mismatch() Iter[int] {
+	Yield("1")
+	return nil
+}
+
+func Nested() Iter[int] {
+	println(Yield(1))
+	return nil
+}
+
+func Valid() Iter[int] {
+	Yield(1)
+	return nil
+}
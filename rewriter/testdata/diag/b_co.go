@@ -0,0 +1,18 @@
+//go:build co
+
+package diag
+
+import (
+	. "github.com/goghcrow/go-co"
+)
+
+func BreakBlock(n int) Iter[int] {
+block:
+	{
+		Yield(1)
+		if n > 0 {
+			break block
+		}
+	}
+	return nil
+}
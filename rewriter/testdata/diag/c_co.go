@@ -0,0 +1,39 @@
+//go:build co
+
+package diag
+
+import (
+	. "github.com/goghcrow/go-co"
+)
+
+func Goroutine() Iter[int] {
+	go func() {
+		Yield(1)
+	}()
+	return nil
+}
+
+func Callback(xs []int) Iter[int] {
+	each(xs, func(x int) {
+		Yield(x)
+	})
+	return nil
+}
+
+func each(xs []int, f func(int)) {
+	for _, x := range xs {
+		f(x)
+	}
+}
+
+func Chan(it Iter[int]) int {
+	v := <-it
+	return v + len(it)
+}
+
+func Indexed(it Iter[int]) (n int) {
+	for i, v := range it {
+		n += i * v
+	}
+	return
+}
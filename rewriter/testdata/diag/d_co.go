@@ -0,0 +1,21 @@
+//go:build co
+
+package diag
+
+import (
+	. "github.com/goghcrow/go-co"
+)
+
+func ReturnMismatch() Generator[int, string] {
+	Yield(1)
+	return Return[int](42)
+}
+
+func ReturnInIter() Iter[int] {
+	Yield(1)
+	return Return[int]("done")
+}
+
+func ReturnOutside() <-chan int {
+	return Return[int]("done")
+}
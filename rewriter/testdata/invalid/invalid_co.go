@@ -0,0 +1,15 @@
+//go:build co
+
+package invalid
+
+import (
+	. "github.com/goghcrow/go-co"
+)
+
+// x is typed invalid by the undefined, which can't be hoisted by the state machine
+func Invalid() Iter[int] {
+	x := undefined()
+	Yield(1)
+	println(x)
+	return nil
+}
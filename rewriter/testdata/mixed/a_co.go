@@ -0,0 +1,18 @@
+//go:build co
+
+package mixed
+
+import (
+	. "github.com/goghcrow/go-co"
+)
+
+func Repeat(s string, n int) Iter[string] {
+	for i := 0; i < n; i++ {
+		Yield(s)
+	}
+	return nil
+}
+
+func First(it Iter[string]) string {
+	return <-it
+}
@@ -0,0 +1,12 @@
+package mixed
+
+func Join(xs []string, sep string) string {
+	s := ""
+	for i, x := range xs {
+		if i > 0 {
+			s += sep
+		}
+		s += x
+	}
+	return s
+}
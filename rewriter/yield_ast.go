@@ -2,19 +2,25 @@ package rewriter
 
 import (
 	"go/ast"
+	"go/token"
+	"strconv"
 )
 
 type yieldAst struct {
 	seqImportedName string
 	funRetParamTy   ast.Expr // generator element type
+	funResultTy     ast.Expr // generator result type, nil if absent
+	funSendTy       ast.Expr // generator sent type, nil if the same as the element type
 
 	callNormal *ast.CallExpr // for fast equivalence check
 }
 
-func mkYieldAst(seqName string, retParamTy ast.Expr) *yieldAst {
+func mkYieldAst(seqName string, retParamTy, resultTy, sendTy ast.Expr) *yieldAst {
 	a := &yieldAst{
 		seqImportedName: seqName,
 		funRetParamTy:   retParamTy,
+		funResultTy:     resultTy,
+		funSendTy:       sendTy,
 	}
 	a.callNormal = a.CallNormal()
 	return a
@@ -46,6 +52,33 @@ func (y *yieldAst) SeqCall(name string, args ...ast.Expr) *ast.CallExpr {
 	}
 }
 
+// e.g. ResultIterator[T, R]
+func (y *yieldAst) SeqResultIndex(name string) *ast.IndexListExpr {
+	return X.Indices(
+		y.SeqSelect(name),
+		y.funRetParamTy,
+		y.funResultTy,
+	)
+}
+
+// e.g. Generator[T, S, R]
+func (y *yieldAst) SeqGeneratorIndex(name string) *ast.IndexListExpr {
+	return X.Indices(
+		y.SeqSelect(name),
+		y.funRetParamTy,
+		y.funSendTy,
+		y.funResultTy,
+	)
+}
+
+// the type of the value received by yield expr
+func (y *yieldAst) RecvType() ast.Expr {
+	if y.funSendTy != nil {
+		return y.funSendTy
+	}
+	return y.funRetParamTy
+}
+
 func (y *yieldAst) Thunk(body *ast.BlockStmt) *ast.FuncLit {
 	return &ast.FuncLit{
 		Type: &ast.FuncType{
@@ -64,6 +97,26 @@ func (y *yieldAst) CallStart(body *ast.BlockStmt) *ast.CallExpr {
 	)
 }
 
+func (y *yieldAst) CallStartSeq(body *ast.BlockStmt) *ast.CallExpr {
+	return y.SeqCall(cstStartSeq,
+		y.CallDelay(body),
+	)
+}
+
+func (y *yieldAst) CallStartResult(body *ast.BlockStmt) *ast.CallExpr {
+	return &ast.CallExpr{
+		Fun:  y.SeqResultIndex(cstStartRet),
+		Args: []ast.Expr{y.CallDelay(body)},
+	}
+}
+
+func (y *yieldAst) CallStartGenerator(body *ast.BlockStmt) *ast.CallExpr {
+	return &ast.CallExpr{
+		Fun:  y.SeqGeneratorIndex(cstStartGen),
+		Args: []ast.Expr{y.CallDelay(body)},
+	}
+}
+
 func (y *yieldAst) CallNormal() *ast.CallExpr {
 	return y.SeqCall(cstNormal)
 }
@@ -72,6 +125,17 @@ func (y *yieldAst) CallReturn() *ast.CallExpr {
 	return y.SeqCall(cstReturn)
 }
 
+func (y *yieldAst) CallReturnValue(v ast.Expr) *ast.CallExpr {
+	return &ast.CallExpr{
+		Fun:  y.SeqResultIndex(cstReturnV),
+		Args: []ast.Expr{v},
+	}
+}
+
+func (y *yieldAst) CallReturnError(err ast.Expr) *ast.CallExpr {
+	return y.SeqCall(cstReturnE, err)
+}
+
 func (y *yieldAst) CallBreak() *ast.CallExpr {
 	return y.SeqCall(cstBreak)
 }
@@ -80,6 +144,46 @@ func (y *yieldAst) CallContinue() *ast.CallExpr {
 	return y.SeqCall(cstContinue)
 }
 
+func (y *yieldAst) CallBreakLabel(label *ast.Ident) *ast.CallExpr {
+	return y.SeqCall(cstBreakLabel, y.Label(label))
+}
+
+func (y *yieldAst) CallContinueLabel(label *ast.Ident) *ast.CallExpr {
+	return y.SeqCall(cstContinueLabel, y.Label(label))
+}
+
+func (y *yieldAst) CallSelect(body ast.Expr) *ast.CallExpr {
+	return y.SeqCall(cstSelect, body)
+}
+
+func (y *yieldAst) CallSwitch(body ast.Expr) *ast.CallExpr {
+	return y.SeqCall(cstSwitch, body)
+}
+
+func (y *yieldAst) CallLabeled(label *ast.Ident, body ast.Expr) *ast.CallExpr {
+	return y.SeqCall(cstLabeled, y.Label(label), body)
+}
+
+func (y *yieldAst) CallDispatch(pc string, blocks []ast.Expr) *ast.CallExpr {
+	args := []ast.Expr{y.Label(X.Ident(pc)), X.Unary(token.AND, X.Ident(pc))}
+	return y.SeqCall(cstDispatch, append(args, blocks...)...)
+}
+
+func (y *yieldAst) CallGoto(pc string, idx int) *ast.CallExpr {
+	return y.SeqCall(cstGoto,
+		y.Label(X.Ident(pc)),
+		X.Unary(token.AND, X.Ident(pc)),
+		&ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(idx)},
+	)
+}
+
+func (y *yieldAst) Label(label *ast.Ident) *ast.BasicLit {
+	return &ast.BasicLit{
+		Kind:  token.STRING,
+		Value: strconv.Quote(label.Name),
+	}
+}
+
 func (y *yieldAst) CallDelay(body *ast.BlockStmt) *ast.CallExpr {
 	return y.SeqCall(
 		cstDelay,
@@ -94,6 +198,32 @@ func (y *yieldAst) CallBind(v ast.Expr, body *ast.BlockStmt) *ast.CallExpr {
 	)
 }
 
+func (y *yieldAst) CallBindFrom(it ast.Expr, body *ast.BlockStmt) *ast.CallExpr {
+	return y.SeqCall(cstBindFrom,
+		it,
+		y.Thunk(body),
+	)
+}
+
+func (y *yieldAst) CallBindRecv(v ast.Expr, recv *ast.Ident, body *ast.BlockStmt) *ast.CallExpr {
+	thunk := y.Thunk(body)
+	thunk.Type.Params = X.Fields(&ast.Field{
+		Names: []*ast.Ident{recv},
+		Type:  y.RecvType(),
+	})
+	return y.SeqCall(cstBindRecv,
+		v,
+		thunk,
+	)
+}
+
+func (y *yieldAst) CallDefer(f ast.Expr, body *ast.BlockStmt) *ast.CallExpr {
+	return y.SeqCall(cstDefer,
+		f,
+		y.Thunk(body),
+	)
+}
+
 func (y *yieldAst) CallCombine(s1, s2 *ast.BlockStmt) *ast.CallExpr {
 	return y.SeqCall(cstCombine,
 		y.CallDelay(s1),
@@ -101,14 +231,27 @@ func (y *yieldAst) CallCombine(s1, s2 *ast.BlockStmt) *ast.CallExpr {
 	)
 }
 
-func (y *yieldAst) CallFor(cond, post, body ast.Expr) *ast.CallExpr {
+func (y *yieldAst) CallCombineSeq(s1, s2 ast.Expr) *ast.CallExpr {
+	return y.SeqCall(cstCombine, s1, s2)
+}
+
+func (y *yieldAst) CallFor(label *ast.Ident, cond, post, body ast.Expr) *ast.CallExpr {
+	nilIfAbsent := func(e ast.Expr) ast.Expr {
+		if isNil(e) {
+			return X.Ident("nil")
+		}
+		return e
+	}
+	if label != nil {
+		return y.SeqCall(cstLabeledFor, y.Label(label), nilIfAbsent(cond), nilIfAbsent(post), body)
+	}
 	if isNil(cond) && isNil(post) {
 		return y.SeqCall(cstLoop, body)
 	}
 	if isNil(post) {
 		return y.SeqCall(cstWhile, cond, body)
 	}
-	return y.SeqCall(cstFor, cond, post, body)
+	return y.SeqCall(cstFor, nilIfAbsent(cond), post, body)
 }
 
 func (y *yieldAst) ForCondFun(cond ast.Expr) *ast.FuncLit {
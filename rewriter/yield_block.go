@@ -11,10 +11,14 @@ const (
 	kindSwitch                 // switch stmt which calling yield
 
 	// ↓↓↓ MUST be constructed with return stmt
-	kindNormal  // stmt which calling seq.Normal()
-	kindYield   // stmt which calling seq.Bind()
-	kindCombine // stmt which calling seq.Combine()
-	kindFor     // stmt which calling seq.For()
+	kindNormal    // stmt which calling seq.Normal()
+	kindYield     // stmt which calling seq.Bind()
+	kindDefer     // stmt which calling seq.Defer()
+	kindCombine   // stmt which calling seq.Combine()
+	kindFor       // stmt which calling seq.For()
+	kindLabeled   // stmt which calling seq.Labeled()
+	kindBreakable // stmt which calling seq.Select() / seq.Switch()
+	kindDispatch  // stmt which calling seq.Dispatch()
 	// ↑↑↑ MUST be constructed with return stmt
 )
 
@@ -98,10 +102,14 @@ func (b *block) mayContainsYield() bool {
 	// 	kindTrival: no yield
 	//  kindReturn: no yield
 	//	kindYield: just yield
+	//	kindDefer: defer, the same as kindYield
 	//	kindIf: if-stmt marked kindIf when body contains yield, otherwise trival
 	//	kindFor: for-stmt marked kindFor when body/post contains yield, otherwise trival
 	//	kindCombine: non-trival combined, may contain yield
 	//	kindSwitch: the same as kindIf
+	//	kindLabeled: non-trival labeled stmt, may contain yield
+	//	kindBreakable: select / switch stmt which calling yield, wrapped for break
+	//	kindDispatch: stmts split by goto labels, may contain yield
 	//	kindDelay: shouldn't exist, details can refer to comment in BlockStmt rewritten
 
 	// fast routine
@@ -109,7 +117,7 @@ func (b *block) mayContainsYield() bool {
 		return false
 	}
 	switch b.lastKind() {
-	case kindYield, kindFor, kindCombine: // ending with return
+	case kindYield, kindDefer, kindFor, kindCombine, kindLabeled, kindBreakable, kindDispatch: // ending with return
 		return true
 	default: // make ide happy
 	}
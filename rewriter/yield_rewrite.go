@@ -4,7 +4,7 @@ import (
 	"go/ast"
 	"go/token"
 	"go/types"
-	"log"
+	"strconv"
 
 	"github.com/goghcrow/go-loader"
 	"github.com/goghcrow/go-matcher"
@@ -18,11 +18,20 @@ type yieldRewriter struct {
 
 	funcTyp  *ast.FuncType
 	funcBody *ast.BlockStmt
+	stdSeq   bool // return iter.Seq[V] instead of co.Iter[V]
+	errIter  bool // return co.ErrIter[V], which can `return err`
+	iter2    bool // return co.Iter2[K, V], which yields by Yield2
 	*yieldAst
 
 	// file scope cache
 	rewriteRetCache map[ast.Node]bool
 
+	// yield func scope, for lowering goto
+	initBlocks  map[*ast.BlockStmt]bool // blocks wrapping the extracted init and labeled stmt
+	gotoLabels  map[string]bool         // labels targeted by goto
+	gotoTargets map[string]gotoTarget   // lowered labels
+	dispatches  map[*ast.ReturnStmt]bool
+
 	symCnt int // for unique symbol
 }
 
@@ -37,14 +46,12 @@ func mkYieldRewriter(r *rewriter, pkg loader.Pkg) func(*astutil.Cursor, loader.P
 func (r *yieldRewriter) rewrite(c *astutil.Cursor, pkg loader.Pkg) bool {
 	switch f := c.Node().(type) {
 	case *ast.FuncDecl:
-		if r.rewriter.isYieldFuncDecl(f) {
-			r.rewriteYieldFunc(f.Type, f.Body)
+		if r.rewriter.isYieldFuncDecl(f) && r.rewriter.catch(func() { r.rewriteYieldFunc(f.Type, f.Body) }) {
 			c.Replace(f)
 		}
 		return true
 	case *ast.FuncLit:
-		if r.rewriter.isYieldFuncLit(f) {
-			r.rewriteYieldFunc(f.Type, f.Body)
+		if r.rewriter.isYieldFuncLit(f) && r.rewriter.catch(func() { r.rewriteYieldFunc(f.Type, f.Body) }) {
 			c.Replace(f)
 		}
 		return true
@@ -58,15 +65,30 @@ func (r *yieldRewriter) rewriteYieldFunc(
 ) {
 	r.funcTyp = funTy
 	r.funcBody = body
+	r.stdSeq = r.rewriter.isStdSeq(r.pkg.TypeOf(funTy.Results.List[0].Type))
+	r.errIter = r.rewriter.isErrIter(r.pkg.TypeOf(funTy.Results.List[0].Type))
+	r.iter2 = r.rewriter.isIter2(r.pkg.TypeOf(funTy.Results.List[0].Type))
 	r.yieldAst = mkYieldAst(
 		r.rewriter.seqImportedName,
 		r.rewriter.yieldFuncRetParamTy(r.pkg, funTy),
+		r.rewriter.yieldFuncResultTy(r.pkg, funTy),
+		r.rewriter.yieldFuncSendTy(r.pkg, funTy),
 	)
+	r.initBlocks = map[*ast.BlockStmt]bool{}
+	r.gotoTargets = map[string]gotoTarget{}
+	r.dispatches = map[*ast.ReturnStmt]bool{}
 	// not support recursive, no need stack
 	defer func() {
 		r.funcTyp = nil
 		r.funcBody = nil
+		r.stdSeq = false
+		r.errIter = false
+		r.iter2 = false
 		r.yieldAst = nil
+		r.initBlocks = nil
+		r.gotoLabels = nil
+		r.gotoTargets = nil
+		r.dispatches = nil
 	}()
 
 	// may be ignored, rewriteIter do the same thing
@@ -75,10 +97,27 @@ func (r *yieldRewriter) rewriteYieldFunc(
 }
 
 func (r *yieldRewriter) rewriteYieldFuncResult() {
+	if r.stdSeq {
+		return // iter.Seq[V] kept
+	}
+	if r.funSendTy != nil {
+		r.funcTyp.Results.List[0].Type = r.SeqGeneratorIndex(cstGenerator)
+		return
+	}
+	if r.funResultTy != nil {
+		r.funcTyp.Results.List[0].Type = r.SeqResultIndex(cstResultIterator)
+		return
+	}
 	r.funcTyp.Results.List[0].Type = r.SeqType(cstIterator)
 }
 
 // >>> return Start(Delay[T](func() Seq[T] { ... }))
+// or
+// >>> return StartSeq(Delay[T](func() Seq[T] { ... })) // iter.Seq[T]
+// or
+// >>> return StartResult[T, R](Delay[T](func() Seq[T] { ... })) // co.Generator[T, R]
+// or
+// >>> return StartGenerator[T, S, R](Delay[T](func() Seq[T] { ... })) // co.Coroutine[T, S, R]
 func (r *yieldRewriter) rewriteYieldFuncBody() {
 	// pass0
 	// 1. rewrite `return` or `return nil` to return seq.Return() in yield func
@@ -97,7 +136,8 @@ func (r *yieldRewriter) rewriteYieldFuncBody() {
 	// and why rewriting ReturnStmt firstly?
 	// cause of easy to recognize yield func before any rewriting
 	//
-	// 2. extract out define in for-init/switch-init in yield func
+	// 2. extract out define in for-init/switch-init in yield func,
+	// and if-init containing yield expr
 	//
 	// keep shadow semantics by adding extra block,
 	// prevent name conflict name in the scope after rewriteYield
@@ -121,22 +161,44 @@ func (r *yieldRewriter) rewriteYieldFuncBody() {
 	// pass1
 	r.rewriteRanges(r.funcBody)
 
+	// compile to state machine instead of pass2 and pass3 if supported
+	if r.rewriter.machine {
+		if body, ok := r.compileMachine(); ok {
+			r.funcBody.List = []ast.Stmt{X.Return(r.callStart(body))}
+			return
+		}
+	}
+
 	// bootstrap
 	// start(delay(func() { kindDelay }))
 	following := mkBlock(kindDelay /*callback func lit body*/)
 
 	// pass2
 	// skipping ReturnStmt, which rewritten in pass0
+	// lowering goto stmt into seq.Dispatch if necessary
+	r.gotoLabels = r.collectGotoLabels(r.funcBody)
 	r.rewriteStmts(r.funcBody.List, 0, following)
 
 	// pass3
-	// we will rewrite break/continue in the second pass
+	// we will rewrite break/continue/goto in the second pass
 	// because we don't know whether to keep break/continue in trival context,
 	// or to replace with co.Break() co.Continue() in monadic context
 	r.rewriteBreakContinues(following.block)
 
-	returnCallStart := X.Return(r.CallStart(following.block))
-	r.funcBody.List = []ast.Stmt{returnCallStart}
+	r.funcBody.List = []ast.Stmt{X.Return(r.callStart(following.block))}
+}
+
+func (r *yieldRewriter) callStart(body *ast.BlockStmt) *ast.CallExpr {
+	switch {
+	case r.stdSeq:
+		return r.CallStartSeq(body)
+	case r.funSendTy != nil:
+		return r.CallStartGenerator(body)
+	case r.funResultTy != nil:
+		return r.CallStartResult(body)
+	default:
+		return r.CallStart(body)
+	}
 }
 
 func (r *yieldRewriter) rewriteStmts(
@@ -144,6 +206,10 @@ func (r *yieldRewriter) rewriteStmts(
 	idx int,
 	children *block,
 ) {
+	if idx == 0 {
+		stmts = r.lowerGotoIfNecessary(stmts)
+	}
+
 	done := idx >= len(stmts)
 	if done {
 		if children.kind == kindDelay {
@@ -153,7 +219,9 @@ func (r *yieldRewriter) rewriteStmts(
 	}
 
 	isLast := idx == len(stmts)-1
+	n := children.len()
 	following := r.rewriteStmt(stmts[idx], isLast, children)
+	r.rewriter.mapStmtPos(children.block.List[minInt(n, children.len()):], stmts[idx])
 	if following == nil {
 		return
 	}
@@ -179,7 +247,7 @@ func (r *yieldRewriter) rewriteStmt(
 		panic("illegal state")
 
 	case *ast.BadStmt:
-		r.assert(false, stmt, "bad stmt")
+		r.assert(false, stmt, CodeInternal, "bad stmt")
 		panic("make compiler happy")
 
 	case *ast.BlockStmt:
@@ -221,13 +289,35 @@ func (r *yieldRewriter) rewriteStmt(
 			} else {
 				// switch children to empty binding callback body (following)
 				// no need combine cause of body is empty
-				r.assert(following != children, stmt, "illegal state")
+				r.assert(following != children, stmt, CodeInternal, "illegal state")
 				return following
 			}
 		} else {
 			// ↓↓ trival branch ↓↓
 			// rewrite next stmt in current block
 			// no need combine cause of prev stmt is trival
+			r.assertNoYieldExpr(stmt)
+			children.push(stmt, kindTrival)
+			return children
+		}
+
+	case *ast.AssignStmt:
+		// rewrite yield expr
+		if assign, call, ok := r.rewriter.isYieldRecvCall(r.pkg, stmt); ok {
+			r.checkYieldCall(call)
+			r.checkYieldRecvCall(call)
+
+			// ↓↓ non-trival branch ↓↓
+			following := r.rewriteYieldRecvCall(assign, call, children)
+			if isLast {
+				r.generateLastNormalIfNecessary(following) // MUST
+				return nil                                 // last stmt, no following
+			} else {
+				return following
+			}
+		} else {
+			// ↓↓ trival branch ↓↓
+			r.assertNoYieldExpr(stmt)
 			children.push(stmt, kindTrival)
 			return children
 		}
@@ -240,12 +330,13 @@ func (r *yieldRewriter) rewriteStmt(
 			// fallthrough supported only in trival switch node
 			children.push(stmt, kindTrival)
 			// ignore dead code after return break/ continue,
-			// only goto or labeled-stmt can reach the stmts after break/continue
-			// no need to rewrite next, cause of goto or labeled-stmt unsupported
+			// only goto can reach the stmts after break/continue,
+			// the stmts are split by goto labels before rewriting
 			return nil // ignore dead code, no following
 		case token.GOTO:
-			r.assert(false, stmt, "goto not supported")
-			panic("make compiler happy")
+			// rewritten to return Goto() in pass3 if label lowered
+			children.push(stmt, kindTrival)
+			return nil // ignore dead code, no following
 		default:
 			panic("unreached")
 		}
@@ -272,35 +363,63 @@ func (r *yieldRewriter) rewriteStmt(
 	case *ast.TypeSwitchStmt:
 		// ↓↓ non-trival branch ↓↓
 		trivalAssign := r.mustNoYield(stmt.Assign)
-		r.assert(trivalAssign, stmt.Assign, "yield not allowed")
+		r.assert(trivalAssign, stmt.Assign, CodeYieldExpr, "yield not allowed in type switch guard")
 		return r.rewriteSwitchStmt(
 			stmt, &stmt.Init, stmt.Assign, stmt.Body, &stmt.Switch, children,
 		)
 
 	case *ast.ForStmt:
 		// ↓↓ non-trival branch ↓↓
-		return r.rewriteForStmt(stmt, children)
+		return r.rewriteForStmt(stmt, nil, children)
+
+	case *ast.LabeledStmt:
+		if r.mustNoYield(stmt) {
+			// ↓↓ trival branch ↓↓
+			// label is kept, break/continue/goto with label works natively
+			children.push(stmt, kindTrival)
+			return children
+		}
+		// ↓↓ non-trival branch ↓↓
+		return r.rewriteLabeledStmt(stmt, children)
 
 	// rewritten in pass1
 	// case *ast.RangeStmt:
 
-	case *ast.SelectStmt, *ast.CommClause,
-		*ast.LabeledStmt, *ast.CaseClause,
-		*ast.DeferStmt:
-		r.assert(false, stmt, "%T implement me", stmt)
+	case *ast.SelectStmt:
+		// ↓↓ non-trival branch ↓↓
+		return r.rewriteSelectStmt(stmt, children)
+
+	case *ast.DeferStmt:
+		// ↓↓ non-trival branch ↓↓
+		following := r.rewriteDeferStmt(stmt, children)
+		if isLast {
+			r.generateLastNormalIfNecessary(following) // MUST
+			return nil                                 // last stmt, no following
+		} else {
+			return following
+		}
+
+	case *ast.CommClause, *ast.CaseClause:
+		r.assert(false, stmt, CodeUnsupported, "%T not supported in yield func", stmt)
 		panic("make compiler happy")
 
-	// rewritten in pass0
-	// case *ast.ReturnStmt:
-	// // ↓↓ non-trival branch ↓↓
-	// // Notice: ignore stmt.Results
-	// r.generateReturnStmt(children)
-	// return
+	case *ast.ReturnStmt:
+		if r.dispatches[stmt] {
+			// ↓↓ non-trival branch ↓↓
+			// generated by lowerGotoIfNecessary
+			children.pushReturn(stmt.Results[0].(*ast.CallExpr), kindDispatch)
+			return nil // last stmt, no following
+		}
+		// ↓↓ trival branch ↓↓
+		// rewritten in pass0
+		children.push(stmt, kindTrival)
+		return children
 
 	default:
 		// ↓↓ trival branch ↓↓
 		// all other stmt are trival,
 		// no rewriting, no combine
+		r.assertNoYieldExpr(stmt)
 		children.push(stmt, kindTrival)
 		return children
 	}
@@ -327,13 +446,30 @@ func (r *yieldRewriter) generateLastNormalIfNecessary(children *block) {
 	}
 }
 func (r *yieldRewriter) checkYieldCall(call *ast.CallExpr) {
+	if r.rewriter.isYield2Call(r.pkg, call) {
+		r.assert(r.iter2, call.Lparen, CodeYieldTypeMismatch, "Yield2(k, v) only supported in yield func returning co.Iter2[K, V]")
+		r.checkYield2Call(call)
+		return
+	}
+	r.assert(!r.iter2, call.Lparen, CodeYieldTypeMismatch, "use Yield2(k, v) in yield func returning co.Iter2[K, V]")
+	if r.rewriter.isYieldFromCall(r.pkg, call) {
+		r.checkYieldFromCall(call)
+		return
+	}
+
 	v := r.pkg.TypeOf(call.Args[0])
 	t := r.pkg.TypeOf(r.yieldAst.funRetParamTy)
 	// generated codes have attached the type
 	assert(v != nil && t != nil)
+	if v == types.Typ[types.Invalid] {
+		// the type error has been reported by loader,
+		// e.g., `Yield(v+1)` with v of `for i, v := range it`,
+		// left to the compiler after rewriting
+		return
+	}
 
 	arg := r.pkg.ShowNode(call.Args[0])
-	r.assert(types.AssignableTo(v, t), call.Lparen,
+	r.assert(types.AssignableTo(v, t), call.Lparen, CodeYieldTypeMismatch,
 		"yield(%s):"+
 			" type mismatch, typeof(%s) is %s, "+
 			"not assignable to return type %s",
@@ -341,19 +477,249 @@ func (r *yieldRewriter) checkYieldCall(call *ast.CallExpr) {
 		v.String(), t.String())
 }
 
+func (r *yieldRewriter) checkYield2Call(call *ast.CallExpr) {
+	kv := r.funRetParamTy.(*ast.IndexListExpr).Indices // seq.Pair[K, V]
+	for i, arg := range call.Args {
+		v := r.pkg.TypeOf(arg)
+		t := r.pkg.TypeOf(kv[i])
+		assert(v != nil && t != nil)
+
+		s := r.pkg.ShowNode(arg)
+		r.assert(types.AssignableTo(v, t), call.Lparen, CodeYieldTypeMismatch,
+			"yield2(%s):"+
+				" type mismatch, typeof(%s) is %s, "+
+				"not assignable to %s",
+			s, s,
+			v.String(), t.String())
+	}
+}
+
+// the delegated co.Iter[V] is bound directly, so V must be identical to the yield type
+func (r *yieldRewriter) checkYieldFromCall(call *ast.CallExpr) {
+	it := r.pkg.TypeOf(call.Args[0])
+	t := r.pkg.TypeOf(r.yieldAst.funRetParamTy)
+	assert(it != nil && t != nil)
+
+	arg := r.pkg.ShowNode(call.Args[0])
+	named, ok := it.(*types.Named)
+	r.assert(ok && named.Obj() == r.rewriter.iterType, call.Lparen, CodeYieldTypeMismatch,
+		"yieldFrom(%s): typeof(%s) is %s, not co.Iter", arg, arg, it.String())
+
+	v := named.TypeArgs().At(0)
+	r.assert(types.Identical(v, t), call.Lparen, CodeYieldTypeMismatch,
+		"yieldFrom(%s):"+
+			" type mismatch, typeof(%s) is %s, "+
+			"not identical to co.Iter[%s]",
+		arg, arg,
+		it.String(), t.String())
+}
+
+// yield expr can't be nested in other expr
+func (r *yieldRewriter) assertNoYieldExpr(stmt ast.Stmt) {
+	r.assert(r.mustNoYield(stmt), stmt, CodeYieldExpr,
+		"yield expr only supported in the form of `v := Yield(x)` or `v = Yield(x)`")
+}
+
+// the sent value is typed as the yield type T, or S of co.Coroutine[T, S, R],
+// instead of the type inferred from the yielded value
+func (r *yieldRewriter) checkYieldRecvCall(call *ast.CallExpr) {
+	v := r.pkg.TypeOf(call)
+	t := r.pkg.TypeOf(r.yieldAst.RecvType())
+	hint := "Yield[%s](...)"
+	if r.yieldAst.funSendTy != nil {
+		hint = "YieldRecv[%s](...)"
+	}
+	r.assert(types.Identical(v, t), call.Lparen, CodeYieldTypeMismatch,
+		"yield expr: type mismatch, typeof(%s) is %s, not %s, try "+hint,
+		r.pkg.ShowNode(call), v.String(), t.String(), t.String())
+}
+
+// v := Yield($x)
+// =>
+// return BindRecv($x, func(v T) Seq[T] { $following })
+//
+// $lhs = Yield($x)
+// =>
+// return BindRecv($x, func(ʀ T) Seq[T] { $lhs = ʀ; $following })
+func (r *yieldRewriter) rewriteYieldRecvCall(
+	assign *ast.AssignStmt,
+	call *ast.CallExpr,
+	children *block,
+) *block {
+	following := mkBlock(kindDelay /*callback func lit body*/)
+	recv, ok := assign.Lhs[0].(*ast.Ident)
+	if !ok || assign.Tok != token.DEFINE {
+		recv = X.Ident(r.gensym(cstRecvVar))
+		following.push(X.Assign(token.ASSIGN, assign.Lhs[0], recv), kindTrival)
+	}
+	callBindRecv := r.CallBindRecv(call.Args[0], recv, following.block)
+	children.pushReturn(callBindRecv, kindYield)
+	return following
+}
+
 // return Bind($v, func() Seq[T] { $following })
+//
+// Yield2($k, $v)
+// =>
+// return Bind(seq.Pair[K, V]{Key: $k, Val: $v}, func() Seq[T] { $following })
+//
+// YieldFrom($it)
+// =>
+// return BindFrom($it, func() Seq[T] { $following })
 func (r *yieldRewriter) rewriteYieldCall(
 	call *ast.CallExpr,
 	children *block,
 ) *block {
 	// bind(v, func() { kindDelay })
 	following := mkBlock(kindDelay /*callback func lit body*/)
-	v := call.Args[0]
-	callBind := r.CallBind(v, following.block)
+	if r.rewriter.isYieldFromCall(r.pkg, call) {
+		callBindFrom := r.CallBindFrom(call.Args[0], following.block)
+		children.pushReturn(callBindFrom, kindYield)
+		return following
+	}
+	callBind := r.CallBind(r.yieldValue(call), following.block)
 	children.pushReturn(callBind, kindYield)
 	return following
 }
 
+// the value yielded by Yield(v), or seq.Pair[K, V]{Key: k, Val: v} by Yield2(k, v)
+func (r *yieldRewriter) yieldValue(call *ast.CallExpr) ast.Expr {
+	if !r.rewriter.isYield2Call(r.pkg, call) {
+		return call.Args[0]
+	}
+	return &ast.CompositeLit{
+		Type: r.funRetParamTy,
+		Elts: []ast.Expr{
+			&ast.KeyValueExpr{Key: X.Ident(cstPairKey), Value: call.Args[0]},
+			&ast.KeyValueExpr{Key: X.Ident(cstPairVal), Value: call.Args[1]},
+		},
+	}
+}
+
+// function value and parameters are evaluated as usual when the defer executes,
+// but the deferred call is pushed to the defer stack of the generator
+// instead of the one of the current (thunk) func
+//
+//	defer $f()
+//	=>
+//	return Defer($f, func() Seq[T] { $following })
+//
+//	defer $f($args...)
+//	=>
+//	ɗ, ɗ0, ɗ1 := $f, $arg0, $arg1
+//	return Defer(func() { ɗ(ɗ0, ɗ1) }, func() Seq[T] { $following })
+//
+// notice: recover() works only if the deferred func is $f itself, e.g.,
+// defer func() { recover() }()
+func (r *yieldRewriter) rewriteDeferStmt(
+	stmt *ast.DeferStmt,
+	children *block,
+) *block {
+	// following of the defer stmt
+	// defer(f, func() { kindDelay })
+	following := mkBlock(kindDelay /*callback func lit body*/)
+
+	eval, thunk := r.deferredThunk(stmt.Call)
+	if eval != nil {
+		// each deferred call is followed by a new thunk (scope),
+		// so, there is no name conflict
+		children.push(eval, kindTrival)
+		children.markCombined() // no need to combine trival stmt
+	}
+
+	callDefer := r.CallDefer(thunk, following.block)
+	children.pushReturn(callDefer, kindDefer)
+	return following
+}
+
+// the thunk pushed to the defer stack, and the stmt evaluating
+// the func value and parameters of the deferred call, nil if no need
+func (r *yieldRewriter) deferredThunk(call *ast.CallExpr) (eval ast.Stmt, thunk ast.Expr) {
+	info := r.pkg.TypeInfo()
+
+	// func(), evaluated when Defer(...) called
+	if sig, ok := info.TypeOf(call.Fun).(*types.Signature); ok && len(call.Args) == 0 {
+		isThunk := sig.Params().Len() == 0 && sig.Results().Len() == 0
+		if isThunk {
+			return nil, call.Fun
+		}
+	}
+	// func() { ... } generated by the rewriting, which is untyped
+	if lit, ok := call.Fun.(*ast.FuncLit); ok && len(call.Args) == 0 && info.TypeOf(lit) == nil {
+		if lit.Type.Params.NumFields() == 0 && lit.Type.Results.NumFields() == 0 {
+			return nil, lit
+		}
+	}
+
+	var (
+		lhs, rhs []ast.Expr
+		evalTo   = func(name string, e ast.Expr) ast.Expr {
+			lhs = append(lhs, X.Ident(name))
+			rhs = append(rhs, e)
+			return X.Ident(name)
+		}
+		// func lit, func or builtin, no need to evaluate
+		isStaticFun = func(f ast.Expr) bool {
+			var id *ast.Ident
+			switch f := astutil.Unparen(f).(type) {
+			case *ast.FuncLit:
+				return true
+			case *ast.Ident:
+				id = f
+			case *ast.SelectorExpr:
+				if info.Selections[f] != nil {
+					return false // method value, receiver evaluated
+				}
+				id = f.Sel // qualified identifier
+			default:
+				return false
+			}
+			switch info.Uses[id].(type) {
+			case *types.Func, *types.Builtin:
+				return true
+			default:
+				return false
+			}
+		}
+		// constant or nil or func lit, no need to evaluate
+		isStaticArg = func(arg ast.Expr) bool {
+			if _, ok := astutil.Unparen(arg).(*ast.FuncLit); ok {
+				return true
+			}
+			tv := info.Types[arg]
+			return tv.Value != nil || tv.IsNil()
+		}
+	)
+
+	deferred := &ast.CallExpr{
+		Fun:      call.Fun,
+		Args:     make([]ast.Expr, len(call.Args)),
+		Ellipsis: call.Ellipsis,
+	}
+	if !isStaticFun(call.Fun) {
+		deferred.Fun = evalTo(cstDeferVar, call.Fun)
+	}
+	for i, arg := range call.Args {
+		if isStaticArg(arg) {
+			deferred.Args[i] = arg
+		} else {
+			deferred.Args[i] = evalTo(cstDeferVar+strconv.Itoa(i), arg)
+		}
+	}
+
+	if len(lhs) > 0 {
+		eval = X.Defines(lhs, rhs)
+	}
+	thunk = &ast.FuncLit{
+		Type: &ast.FuncType{
+			Params:  X.Fields(),
+			Results: X.Fields(),
+		},
+		Body: X.Block(X.Stmt(deferred)),
+	}
+	return
+}
+
 func (r *yieldRewriter) rewriteIfStmt(
 	stmt *ast.IfStmt,
 	children *block,
@@ -427,9 +793,15 @@ func (r *yieldRewriter) rewriteSwitchStmt(
 	pos *token.Pos, // maybe modified
 	children *block,
 ) *block {
-	allCaseTrival := true
+	// decide before rewriting, cause of case body may be modified when rewriting
+	breakable := r.breakableSwitchRequired(body)
+
+	allCaseTrival := !breakable
 	var cases []ast.Stmt
 	for _, it := range body.List {
+		if breakable {
+			break
+		}
 		// yield is not supported in case expr, but
 		// yield has no return, no need to assert
 		clause := it.(*ast.CaseClause)
@@ -450,11 +822,16 @@ func (r *yieldRewriter) rewriteSwitchStmt(
 		// details referring to comment in rewriteInitStmt
 		assert(!isDefineStmt(*init))
 		children = r.rewriteStmt(*init, false, children)
-		r.assert(children != nil, stmt, "illegal state")
+		r.assert(children != nil, stmt, CodeInternal, "illegal state")
 		*init = nil
 		*pos = token.NoPos
 	}
 
+	if breakable {
+		children = r.combineIfNecessary(children) // for init containing yield
+		return r.rewriteBreakableSwitchStmt(x, body, children)
+	}
+
 	// stmt.Init non trival
 	if allCaseTrival {
 		switchStmt := X.Switch(
@@ -477,12 +854,202 @@ func (r *yieldRewriter) rewriteSwitchStmt(
 	return children
 }
 
+// select stmt is kept, comm clause body is rewritten as case body of switch,
+// and wrapped with seq.Select, so break after yield completes the select
+//
+//	select {
+//	case $comm:
+//		$body
+//	}
+//	=>
+//	return Select(Delay(func() Seq[T] {
+//		select {
+//		case $comm:
+//			$body
+//		}
+//		return Normal()
+//	}))
+func (r *yieldRewriter) rewriteSelectStmt(
+	stmt *ast.SelectStmt,
+	children *block,
+) *block {
+	allClauseTrival := true
+	var clauses []ast.Stmt
+	for _, it := range stmt.Body.List {
+		clause := it.(*ast.CommClause)
+		trivalComm := r.mustNoYield(clause.Comm)
+		r.assert(trivalComm, clause.Comm, CodeYieldExpr, "yield not allowed in select case")
+		clauseBody := r.rewriteBlockStmt(X.Block(clause.Body...), kindSwitch)
+		clauses = append(clauses, X.CommClause(clause.Comm, clauseBody.block.List))
+		allClauseTrival = allClauseTrival && clauseBody.mustNoYield()
+	}
+
+	// trival routine
+	if allClauseTrival {
+		children.push(stmt, kindTrival)
+		return children
+	}
+
+	body := mkBlock(kindDelay)
+	body.push(X.SelectStmt(X.Block(clauses...)), kindSwitch)
+	r.generateLastNormalIfNecessary(body)
+
+	callSelect := r.CallSelect(r.CallDelay(body.block))
+	children.pushReturn(callSelect, kindBreakable)
+	return children
+}
+
+// the label of labeled monadic stmt is dropped,
+// break/continue with the label will be rewritten to
+// seq.BreakLabel / seq.ContinueLabel in pass3
+//
+//	L: for ... { ... }
+//	=>
+//	return LabeledFor("L", ...)
+//
+//	L: $stmt
+//	=>
+//	return Labeled("L", Delay(func() Seq[T] { $stmt }))
+func (r *yieldRewriter) rewriteLabeledStmt(
+	stmt *ast.LabeledStmt,
+	children *block,
+) *block {
+	switch s := stmt.Stmt.(type) {
+	case *ast.ForStmt:
+		return r.rewriteForStmt(s, stmt.Label, children)
+	default:
+		body := r.rewriteBlockStmt(X.Block(s), kindDelay)
+		callLabeled := r.CallLabeled(stmt.Label, r.CallDelay(body.block))
+		children.pushReturn(callLabeled, kindLabeled)
+		return children
+	}
+}
+
+// when case body contains yield, the rest of case body is moved into callback of seq.Bind,
+// so, fallthrough / break after yield can't work natively
+func (r *yieldRewriter) breakableSwitchRequired(body *ast.BlockStmt) bool {
+	for _, it := range body.List {
+		clause := it.(*ast.CaseClause)
+		if r.mustNoYield(X.Block(clause.Body...)) {
+			continue
+		}
+		if isFallthrough(clause.Body) || hasBreakList(clause.Body, "", true) {
+			return true
+		}
+	}
+	return false
+}
+
+// the case body falling through is rewritten to combining with the seq of next case body,
+// the case body fallen through is shared by the seq variable,
+// and the switch is wrapped with seq.Switch, so break after yield completes the switch
+//
+//	switch $x {
+//	case $a:
+//		$body_a
+//		fallthrough
+//	case $b:
+//		$body_b
+//	case $c:
+//		$body_c
+//	}
+//	=>
+//	return Switch(Delay(func() Seq[T] {
+//		ç1 := Delay(func() Seq[T] { $body_b })
+//		switch $x {
+//		case $a:
+//			return Combine(Delay(func() Seq[T] { $body_a }), ç1)
+//		case $b:
+//			return ç1
+//		case $c:
+//			$body_c
+//		}
+//		return Normal()
+//	}))
+func (r *yieldRewriter) rewriteBreakableSwitchStmt(
+	x ast.Node, // Tag of SwitchStmt | Assign of TypeSwitchStmt
+	body *ast.BlockStmt,
+	children *block,
+) *block {
+	var (
+		n       = len(body.List)
+		clauses = make([]*ast.CaseClause, n)
+		falls   = make([]bool, n) // whether case body ending with fallthrough
+		stmts   = make([][]ast.Stmt, n)
+	)
+	for i, it := range body.List {
+		clause := it.(*ast.CaseClause)
+		clauses[i] = clause
+		stmts[i] = clause.Body
+		if isFallthrough(clause.Body) {
+			falls[i] = true
+			stmts[i] = clause.Body[:len(clause.Body)-1]
+		}
+	}
+
+	var (
+		isTarget = func(i int) bool { return i > 0 && falls[i-1] }
+		caseVar  = func(i int) *ast.Ident { return X.Ident(cstCaseVar + strconv.Itoa(i)) }
+		delay    = func(i int) *ast.CallExpr {
+			caseBody := r.rewriteBlockStmt(X.Block(stmts[i]...), kindDelay)
+			return r.CallDelay(caseBody.block)
+		}
+		caseSeq = func(i int) ast.Expr {
+			if falls[i] {
+				// the last case can't fallthrough
+				return r.CallCombineSeq(delay(i), caseVar(i+1))
+			}
+			return delay(i)
+		}
+	)
+
+	inner := mkBlock(kindDelay)
+
+	// declare the seq of case body fallen through in reverse,
+	// cause of the seq refers to the seq of next case if falling through
+	for i := n - 1; i >= 0; i-- {
+		if isTarget(i) {
+			inner.push(X.Define(caseVar(i), caseSeq(i)), kindTrival)
+			inner.markCombined() // no need to combine trival stmt
+		}
+	}
+
+	var cases []ast.Stmt
+	for i, clause := range clauses {
+		switch {
+		case isTarget(i):
+			cases = append(cases, X.Case(clause.List, []ast.Stmt{X.Return(caseVar(i))}))
+		case falls[i]:
+			cases = append(cases, X.Case(clause.List, []ast.Stmt{X.Return(caseSeq(i))}))
+		default:
+			caseBody := r.rewriteBlockStmt(X.Block(stmts[i]...), kindSwitch)
+			cases = append(cases, X.Case(clause.List, caseBody.block.List))
+		}
+	}
+
+	inner.push(X.Switch(nil, x, X.Block(cases...)), kindSwitch)
+	r.generateLastNormalIfNecessary(inner)
+
+	callSwitch := r.CallSwitch(r.CallDelay(inner.block))
+	children.pushReturn(callSwitch, kindBreakable)
+	return children
+}
+
 func (r *yieldRewriter) rewriteForStmt(
 	stmt *ast.ForStmt,
+	label *ast.Ident, // nil if unlabeled
 	children *block,
 ) *block {
 	body := r.rewriteBlockStmt(stmt.Body, kindFor)
 
+	// keep label in trival routine
+	labeled := func(s ast.Stmt) ast.Stmt {
+		if label == nil {
+			return s
+		}
+		return &ast.LabeledStmt{Label: label, Stmt: s}
+	}
+
 	trivalInit := r.mustNoYield(stmt.Init)
 	trivalPost := r.mustNoYield(stmt.Post)
 	trivalBody := body.mustNoYield()
@@ -490,7 +1057,7 @@ func (r *yieldRewriter) rewriteForStmt(
 	// trival routine
 	allTrival := trivalBody && trivalInit && trivalPost
 	if allTrival {
-		children.push(stmt, kindTrival)
+		children.push(labeled(stmt), kindTrival)
 		return children
 	}
 
@@ -499,7 +1066,7 @@ func (r *yieldRewriter) rewriteForStmt(
 		// details referring to comment in rewriteInitStmt
 		assert(!isDefineStmt(stmt.Init))
 		children = r.rewriteStmt(stmt.Init, false, children)
-		r.assert(children != nil, stmt, "illegal state")
+		r.assert(children != nil, stmt, CodeInternal, "illegal state")
 		stmt.Init = nil
 		stmt.For = token.NoPos
 	}
@@ -507,12 +1074,13 @@ func (r *yieldRewriter) rewriteForStmt(
 	// trival routine
 	if trivalBody && trivalPost {
 		children = r.combineIfNecessary(children) // for init containing yield
-		children.push(stmt, kindTrival)
+		children.push(labeled(stmt), kindTrival)
 		return children
 	}
 
 	if trivalPost {
 		callFor := r.CallFor(
+			label,
 			r.ForCondFun(stmt.Cond),
 			r.ForPostFun(stmt.Post),
 			r.CallDelay(body.block),
@@ -553,6 +1121,7 @@ func (r *yieldRewriter) rewriteForStmt(
 	}
 
 	callFor := r.CallFor(
+		label,
 		r.ForCondFun(stmt.Cond),
 		nil,
 		r.CallDelay(body.block),
@@ -604,6 +1173,13 @@ func (r *yieldRewriter) rewriteReturnAndForSwitchInitStmtInYieldFun(body *ast.Bl
 			tyNil := types.Universe.Lookup("nil")
 			return types.Identical(tyNil.Type(), r.pkg.TypeOf(ret.Results[0]))
 		}
+
+		// blocks wrapping the extracted init
+		extract = func(init ast.Stmt, n ast.Stmt) *ast.BlockStmt {
+			b := X.Block(init, n)
+			r.initBlocks[b] = true
+			return b
+		}
 	)
 
 	astutil.Apply(body, func(c *astutil.Cursor) bool {
@@ -631,38 +1207,72 @@ func (r *yieldRewriter) rewriteReturnAndForSwitchInitStmtInYieldFun(body *ast.Bl
 			if n.Return == token.NoPos {
 				return true // skip generated node
 			}
-			// notice: only rewrite `return` or `return nil` stmt
+			// notice: only rewrite `return` or `return nil` stmt,
+			// and `return r` in yield func returning co.Generator[T, R],
+			// and `return err` in yield func returning co.ErrIter[T],
+			// `return Return[T](r)` is the same as `return r`, which is well typed
 			if inYieldFunc() {
+				if len(n.Results) == 1 {
+					if call, ok := r.rewriter.isCallOf(r.pkg, n.Results[0], r.rewriter.returnFunc); ok {
+						n.Results[0] = call.Args[0]
+					}
+				}
+				if r.funResultTy != nil && !isRetNil(n) {
+					c.Replace(X.Return(r.CallReturnValue(n.Results[0])))
+					return true
+				}
+				if r.errIter && !isRetNil(n) {
+					c.Replace(X.Return(r.CallReturnError(n.Results[0])))
+					return true
+				}
 				if !isRetNil(n) {
-					log.Println("ignore return: " + r.pkg.ShowNode(n))
+					r.rewriter.logf("ignore return: %s\n", r.pkg.ShowNode(n))
 					assert(len(n.Results) == 1)
 					c.InsertBefore(X.IgnoreExpr(n.Results[0]))
 				}
 				c.Replace(X.Return(r.CallReturn()))
 			}
 
+		case *ast.IfStmt:
+			// yield expr in if-init, e.g., `if v := Yield(x); v { ... }`
+			if inYieldFunc() && !r.mustNoYield(n.Init) {
+				init := n.Init
+				n.Init = nil
+				n.If = token.NoPos
+				c.Replace(extract(init, n))
+			}
 		case *ast.ForStmt:
 			if inYieldFunc() && isDefineStmt(n.Init) {
 				init := n.Init
 				n.Init = nil
 				n.For = token.NoPos
-				c.Replace(X.Block(init, n))
+				c.Replace(extract(init, n))
 			}
 		case *ast.SwitchStmt:
 			if inYieldFunc() && isDefineStmt(n.Init) {
 				init := n.Init
 				n.Init = nil
 				n.Switch = token.NoPos
-				c.Replace(X.Block(init, n))
+				c.Replace(extract(init, n))
 			}
 		case *ast.TypeSwitchStmt:
 			if inYieldFunc() && isDefineStmt(n.Init) {
 				init := n.Init
 				n.Init = nil
 				n.Switch = token.NoPos
-				c.Replace(X.Block(init, n))
+				c.Replace(extract(init, n))
 			}
 
+		case *ast.LabeledStmt:
+			// move label into the block wrapping init
+			//	L: { $init; for ; ; { ... } }
+			//	=>
+			//	{ $init; L: for ; ; { ... } }
+			if b, ok := n.Stmt.(*ast.BlockStmt); ok && r.initBlocks[b] {
+				n.Stmt = b.List[1]
+				b.List[1] = n
+				c.Replace(b)
+			}
 		}
 		return true
 	})
@@ -707,27 +1317,43 @@ func (r *yieldRewriter) rewriteBreakContinues(body *ast.BlockStmt) {
 		enterFuncLit = funcLitStack.push
 		exitFuncLit  = funcLitStack.pop
 
+		// labels of the trival labeled stmt, which are kept in the rewritten code
+		labelStack = mkStack[map[string]bool](map[string]bool{})
+		inLabeled  = func(label *ast.Ident) bool { return labelStack.top()[label.Name] }
+
 		doRewrite = func(n *ast.BranchStmt) (_ ast.Node) {
 			switch n.Tok {
 			case token.BREAK:
+				if n.Label != nil {
+					if inLabeled(n.Label) {
+						return
+					}
+					return X.Return(r.CallBreakLabel(n.Label))
+				}
 				if inLoop() || inSwitch() {
 					return
 				}
-				r.assert(n.Label == nil, n, "break with label not supported")
 				return X.Return(r.CallBreak())
 			case token.CONTINUE:
+				if n.Label != nil {
+					if inLabeled(n.Label) {
+						return
+					}
+					return X.Return(r.CallContinueLabel(n.Label))
+				}
 				if inLoop() {
 					return
 				}
-				r.assert(n.Label == nil, n, "continue with label not supported")
 				return X.Return(r.CallContinue())
 			case token.GOTO:
-				r.assert(false, n, "goto not supported")
+				if t, ok := r.gotoTargets[n.Label.Name]; ok && !inLabeled(n.Label) {
+					return X.Return(r.CallGoto(t.pc, t.idx))
+				}
 			case token.FALLTHROUGH:
 				if inSwitch() {
 					return
 				}
-				r.assert(false, n, "fallthrough not supported")
+				r.assert(false, n, CodeInternal, "fallthrough out of switch")
 			default:
 				panic("unreached")
 			}
@@ -756,12 +1382,15 @@ func (r *yieldRewriter) rewriteBreakContinues(body *ast.BlockStmt) {
 		switch n := n.(type) {
 		case *ast.ForStmt, *ast.RangeStmt:
 			enterLoop(true)
-		case *ast.SwitchStmt, *ast.TypeSwitchStmt:
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
 			enterSwitch(true)
+		case *ast.LabeledStmt:
+			labelStack.top()[n.Label.Name] = true
 		case *ast.FuncLit:
 			enterLoop(false)
 			enterSwitch(false)
 			enterFuncLit(n)
+			labelStack.push(map[string]bool{})
 		}
 		return true
 	}, func(c *astutil.Cursor) bool {
@@ -769,12 +1398,15 @@ func (r *yieldRewriter) rewriteBreakContinues(body *ast.BlockStmt) {
 		switch n := n.(type) {
 		case *ast.ForStmt, *ast.RangeStmt:
 			exitLoop()
-		case *ast.SwitchStmt, *ast.TypeSwitchStmt:
+		case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
 			exitSwitch()
+		case *ast.LabeledStmt:
+			delete(labelStack.top(), n.Label.Name)
 		case *ast.FuncLit:
 			exitLoop()
 			exitSwitch()
 			exitFuncLit()
+			labelStack.pop()
 		case *ast.BranchStmt:
 			n1 := doRewrite(n)
 			if n1 != nil {
@@ -815,14 +1447,15 @@ func (r *yieldRewriter) isTerminating(s ast.Stmt) bool {
 		},
 	)
 
-	return mkTerminationChecker(panicCallSites).isTerminating(s)
+	return mkTerminationChecker(panicCallSites).isTerminating(s, "")
 }
 
 func (r *yieldRewriter) assert(
 	ok bool,
 	pos any,
+	code Code,
 	format string,
 	a ...any,
 ) {
-	r.rewriter.assert(r.pkg, ok, pos, format, a...)
+	r.rewriter.assert(r.pkg, ok, pos, code, format, a...)
 }
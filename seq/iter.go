@@ -8,34 +8,42 @@ import "reflect"
 // A "for" statement with a "range" clause iterates through all entries of
 // an array, slice, string or map, or values received on a channel.
 
-type pair[K, V any] struct {
+// Pair is the element of the key-value iterators,
+// e.g., NewMapIter, and the generator yielding by co.Yield2
+type Pair[K, V any] struct {
 	Key K
 	Val V
 }
 
-func NewIntegerIter(n int) Iterator[pair[int, any]] {
+func NewIntegerIter(n int) Iterator[Pair[int, any]] {
 	return &integerIter{n: n}
 }
 
-func NewStringIter(str string) Iterator[pair[int, rune]] {
+func NewStringIter(str string) Iterator[Pair[int, rune]] {
 	return &stringIter{str: []rune(str), idx: -1}
 }
 
-func NewSliceIter[V any](slice []V) Iterator[pair[int, V]] {
+func NewSliceIter[V any](slice []V) Iterator[Pair[int, V]] {
 	return &sliceIter[V]{slice: slice, idx: -1}
 }
 
-func NewMapIter[K comparable, V any](m map[K]V) Iterator[pair[K, V]] {
+func NewMapIter[K comparable, V any](m map[K]V) Iterator[Pair[K, V]] {
 	return &mapIter[K, V]{
 		iter: reflect.ValueOf(m).MapRange(),
 	}
 }
 
-func NewChanIter[V any](ch <-chan V) Iterator[pair[V, any]] {
+func NewChanIter[V any](ch <-chan V) Iterator[Pair[V, any]] {
 	return &chanIter[V]{ch: ch}
 }
 
+// noErr is embedded by the iterators which never fail
+type noErr struct{}
+
+func (noErr) Err() error { return nil }
+
 type integerIter struct {
+	noErr
 	n int
 	i int
 }
@@ -45,11 +53,12 @@ func (i *integerIter) MoveNext() bool {
 	return i.i <= i.n
 }
 
-func (i *integerIter) Current() pair[int, any] {
-	return pair[int, any]{Key: i.i}
+func (i *integerIter) Current() Pair[int, any] {
+	return Pair[int, any]{Key: i.i}
 }
 
 type stringIter struct {
+	noErr
 	str []rune
 	idx int
 }
@@ -59,11 +68,12 @@ func (s *stringIter) MoveNext() bool {
 	return s.idx < len(s.str)
 }
 
-func (s *stringIter) Current() pair[int, rune] {
-	return pair[int, rune]{Key: s.idx, Val: s.str[s.idx]}
+func (s *stringIter) Current() Pair[int, rune] {
+	return Pair[int, rune]{Key: s.idx, Val: s.str[s.idx]}
 }
 
 type sliceIter[V any] struct {
+	noErr
 	slice []V
 	idx   int
 }
@@ -73,11 +83,12 @@ func (s *sliceIter[V]) MoveNext() bool {
 	return s.idx < len(s.slice)
 }
 
-func (s *sliceIter[V]) Current() pair[int, V] {
-	return pair[int, V]{Key: s.idx, Val: s.slice[s.idx]}
+func (s *sliceIter[V]) Current() Pair[int, V] {
+	return Pair[int, V]{Key: s.idx, Val: s.slice[s.idx]}
 }
 
 type mapIter[K comparable, V any] struct {
+	noErr
 	iter *reflect.MapIter
 }
 
@@ -85,14 +96,15 @@ func (m *mapIter[K, V]) MoveNext() bool {
 	return m.iter.Next()
 }
 
-func (m *mapIter[K, V]) Current() pair[K, V] {
-	return pair[K, V]{
+func (m *mapIter[K, V]) Current() Pair[K, V] {
+	return Pair[K, V]{
 		Key: m.iter.Key().Interface().(K),
 		Val: m.iter.Value().Interface().(V),
 	}
 }
 
 type chanIter[V any] struct {
+	noErr
 	ch <-chan V
 	v  V
 }
@@ -102,6 +114,6 @@ func (c *chanIter[V]) MoveNext() (ok bool) {
 	return
 }
 
-func (c *chanIter[V]) Current() pair[V, any] {
-	return pair[V, any]{Key: c.v}
+func (c *chanIter[V]) Current() Pair[V, any] {
+	return Pair[V, any]{Key: c.v}
 }
@@ -0,0 +1,72 @@
+//go:build go1.23
+
+package seq
+
+import "iter"
+
+// helper for rewrite for range statement over function,
+// the push iterator is converted to pull iterator by iter.Pull,
+// params are declared as func type instead of iter.Seq for type inference,
+// so that any func type with the same underlying type can be passed,
+// the rewritten loop closes the iterator on leaving early, which stops the push func
+
+func NewFunc0Iter(f func(yield func() bool)) ClosableIterator[Pair[any, any]] {
+	return NewFuncIter(func(yield func(any) bool) {
+		f(func() bool { return yield(nil) })
+	})
+}
+
+func NewFuncIter[V any](f func(yield func(V) bool)) ClosableIterator[Pair[V, any]] {
+	next, stop := iter.Pull(iter.Seq[V](f))
+	return &funcIter[V]{next: next, stop: stop}
+}
+
+func NewFunc2Iter[K, V any](f func(yield func(K, V) bool)) ClosableIterator[Pair[K, V]] {
+	next, stop := iter.Pull2(iter.Seq2[K, V](f))
+	return &func2Iter[K, V]{next: next, stop: stop}
+}
+
+type funcIter[V any] struct {
+	noErr
+	next func() (V, bool)
+	stop func()
+	v    V
+}
+
+func (f *funcIter[V]) MoveNext() (ok bool) {
+	f.v, ok = f.next()
+	return
+}
+
+func (f *funcIter[V]) Current() Pair[V, any] {
+	return Pair[V, any]{Key: f.v}
+}
+
+// Close stops the push func, the pending deferred calls of it are called
+func (f *funcIter[V]) Close() error {
+	f.stop()
+	return nil
+}
+
+type func2Iter[K, V any] struct {
+	noErr
+	next func() (K, V, bool)
+	stop func()
+	k    K
+	v    V
+}
+
+func (f *func2Iter[K, V]) MoveNext() (ok bool) {
+	f.k, f.v, ok = f.next()
+	return
+}
+
+func (f *func2Iter[K, V]) Current() Pair[K, V] {
+	return Pair[K, V]{Key: f.k, Val: f.v}
+}
+
+// Close stops the push func, the same as funcIter
+func (f *func2Iter[K, V]) Close() error {
+	f.stop()
+	return nil
+}
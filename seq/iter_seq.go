@@ -0,0 +1,87 @@
+//go:build go1.23
+
+package seq
+
+import "iter"
+
+// interop with the standard iter package
+
+// StartSeq starts a new generator every time the iter.Seq is ranged over,
+// for the yield func declaring iter.Seq[V] as return type
+func StartSeq[V any](s Seq[V]) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		ToSeq(Start(s))(yield)
+	}
+}
+
+// ToSeq converts the Iterator to iter.Seq, which can be ranged over only once,
+// the Iterator is closed when ranging stopped if it implements io.Closer
+func ToSeq[V any](it Iterator[V]) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		defer Close(it)
+		for it.MoveNext() {
+			if !yield(it.Current()) {
+				return
+			}
+		}
+	}
+}
+
+// ToSeq2 converts the Iterator of key-value pairs, e.g., NewMapIter, to iter.Seq2
+func ToSeq2[K, V any](it Iterator[Pair[K, V]]) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for p := range ToSeq(it) {
+			if !yield(p.Key, p.Val) {
+				return
+			}
+		}
+	}
+}
+
+// ToSeqErr converts the Iterator to iter.Seq2[V, error],
+// the Err is yielded with the zero value at last if the Iterator failed
+func ToSeqErr[V any](it Iterator[V]) iter.Seq2[V, error] {
+	return func(yield func(V, error) bool) {
+		for v := range ToSeq(it) {
+			if !yield(v, nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			yield(zero[V](), err)
+		}
+	}
+}
+
+// FromSeq converts iter.Seq to Iterator by iter.Pull,
+// the returned Iterator implements io.Closer, which stops the iter.Seq
+func FromSeq[V any](s iter.Seq[V]) Iterator[V] {
+	next, stop := iter.Pull(s)
+	return &pullIter[V]{next: next, stop: stop}
+}
+
+// FromSeq2 converts iter.Seq2 to Iterator of key-value pairs by iter.Pull
+func FromSeq2[K, V any](s iter.Seq2[K, V]) Iterator[Pair[K, V]] {
+	return NewFunc2Iter(s)
+}
+
+type pullIter[V any] struct {
+	noErr
+	next func() (V, bool)
+	stop func()
+	v    V
+}
+
+func (p *pullIter[V]) MoveNext() (ok bool) {
+	p.v, ok = p.next()
+	return
+}
+
+func (p *pullIter[V]) Current() V {
+	return p.v
+}
+
+func (p *pullIter[V]) Close() error {
+	p.stop()
+	return nil
+}
@@ -0,0 +1,84 @@
+package seq
+
+// ━━━━━━━━━━━━━━━━━━━━━━━━━━ 🆂🆃🅰🆃🅴 🅼🅰🅲🅷🅸🅽🅴 ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
+
+// Machine is the state of the yield func compiled to a state machine,
+// the body is re-entered on every resume and jumps to the suspended point by State,
+// the locals of the yield func are hoisted out of the body, so they survive the suspension
+type Machine[V any] struct {
+	State int // the suspended point, 0 at start
+	Recv  any // the value sent by Send, nil if resumed by MoveNext, read by Received
+
+	co        *co[V]
+	k         cont[V]
+	body      func(*Machine[V])
+	from      Iterator[V] // delegated by YieldFrom
+	suspended bool
+
+	// allocated once instead of per yield
+	run    Seq[V]
+	resume func(recv any) Seq[V]
+}
+
+// StateMachine runs the body compiled from the yield func,
+// the alternative of the combinators, which suspends without allocation
+func StateMachine[V any](body func(*Machine[V])) Seq[V] {
+	return func(c *co[V], k cont[V]) {
+		m := &Machine[V]{co: c, k: k, body: body}
+		m.run = func(*co[V], cont[V]) { m.step() }
+		m.resume = func(recv any) Seq[V] {
+			m.Recv = recv
+			return m.run
+		}
+		m.step()
+	}
+}
+
+// run the body until the next yield or returned
+func (m *Machine[V]) step() {
+	if it := m.from; it != nil {
+		m.from = nil
+		if err := it.Err(); err != nil {
+			m.co.err = err
+			m.k(kReturn, zero[V]())
+			return
+		}
+	}
+	m.suspended = false
+	m.body(m)
+	if !m.suspended {
+		m.k(kReturn, zero[V]())
+	}
+}
+
+// Yield suspends the machine with v, the body is resumed at state,
+// the body must return immediately after Yield
+func (m *Machine[V]) Yield(v V, state int) {
+	m.State = state
+	m.suspended = true
+	m.co.step = step[V]{value: v, recv: m.resume, k: m.k}
+}
+
+// YieldFrom suspends the machine delegating to it, the same as BindFrom,
+// the body is resumed at state after it exhausted
+func (m *Machine[V]) YieldFrom(it Iterator[V], state int) {
+	m.State = state
+	m.suspended = true
+	m.from = it
+	m.co.step = step[V]{from: it, recv: m.resume, k: m.k}
+}
+
+// Defer pushes f to the defer stack of the generator, the same as Defer
+func (m *Machine[V]) Defer(f func()) {
+	m.co.defers = append(m.co.defers, f)
+}
+
+// ReturnValue sets the result of the generator, the body returns immediately after it
+func (m *Machine[V]) ReturnValue(r any) {
+	m.co.result = r
+}
+
+// ReturnError finishes the generator with err, the body returns immediately after it
+func (m *Machine[V]) ReturnError(err error) {
+	m.co.err = err
+}
@@ -0,0 +1,73 @@
+package seq
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// PanicError is the panic raised in the generator,
+// which is re-panicked at the consumer's MoveNext / Send,
+// or returned by Err if caught
+type PanicError struct {
+	Value any    // the original panic value
+	Pos   string // file:line of the panicking site in the generator
+	Stack []byte // the stack trace of the panicking goroutine
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("generator panic at %s: %v", e.Pos, e.Value)
+}
+
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// source dir of this package
+var pkgDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// called by the deferred func recovering p,
+// the frames of the panicking site are still on the stack
+func newPanicError(p any) *PanicError {
+	if e, ok := p.(*PanicError); ok {
+		return e // panicked in the nested generator
+	}
+	return &PanicError{
+		Value: p,
+		Pos:   panicPos(),
+		Stack: debug.Stack(),
+	}
+}
+
+// the first frame outside the runtime and this package
+func panicPos() string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		f, more := frames.Next()
+		internal := strings.HasPrefix(f.Function, "runtime.") ||
+			filepath.Dir(f.File) == pkgDir && !strings.HasSuffix(f.File, "_test.go")
+		if !internal {
+			return fmt.Sprintf("%s:%d", f.File, f.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// Catch makes the generator finish instead of panicking when the panic occurs,
+// the panic is available by Err, it has no effect on other iterators
+func Catch[I Iterator[V], V any](it I) I {
+	if c, ok := any(it).(interface{ catch() }); ok {
+		c.catch()
+	}
+	return it
+}
@@ -1,5 +1,11 @@
 package seq
 
+import (
+	"fmt"
+	"io"
+	"reflect"
+)
+
 // ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
 // Monadic 🆈🅸🅴🅻🅳 Implementation
 // ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
@@ -11,30 +17,60 @@ const (
 	kBreak
 	kContinue
 	kReturn
+	kGoto
 )
 
 type (
+	// step is the suspended computation at yield,
+	// the code after yield is resumed by f (or recv) with the continuation k
 	step[V any] struct {
-		value V       // current value
-		next  next[V] // compute the next step
-	}
-	next[V any]     func(recv V) *step[V]   // the next step computation
-	co[V any]       struct{ step *step[V] } // coroutine, which stores the current value and the next step
-	lazy[V any]     func() Seq[V]           // thunk, boxing code after yield for later execution
-	lazyRecv[V any] func(recv V) Seq[V]     // with receive value
-	cont[V any]     func(contType, V)       // continuation
+		value V                     // current value
+		from  Iterator[V]           // delegated iterator by BindFrom, value is ignored if not nil
+		f     lazy[V]               // code after yield statement
+		recv  func(recv any) Seq[V] // code after yield expression, used by BindRecv
+		k     cont[V]               // nil if finished
+	}
+	// coroutine, which stores the current value and the next step
+	// only the yield type V is carried by the combinators,
+	// the sent value and the result are typed by BindRecv / ReturnValue and the generator
+	co[V any] struct {
+		step   step[V]  // reused by every yield instead of allocating
+		label  string   // target label of the pending break/continue, empty if unlabeled
+		defers []func() // stack of deferred calls
+		result any      // set by ReturnValue
+		err    error    // set by ReturnError
+	}
+	lazy[V any]        func() Seq[V]       // thunk, boxing code after yield for later execution
+	lazyRecv[V, S any] func(recv S) Seq[V] // with receive value
+	cont[V any]        func(contType, V)   // continuation
 )
 
 type (
-	Seq[V any]      func(*co[V] /*state*/, cont[V]) // Async Sequence / Async Enumerator
+	Seq[V any] func(*co[V] /*state*/, cont[V]) // Async Sequence / Async Enumerator
+	// Iterator is checked by Err after MoveNext returns false, like bufio.Scanner,
+	// the iterators which never fail return nil
 	Iterator[V any] interface {
 		MoveNext() bool
 		Current() V
+		Err() error
 	}
-	Generator[V any] interface {
+	// ResultIterator is an Iterator with the result of type R,
+	// which is available after exhaustion
+	ResultIterator[V, R any] interface {
 		Iterator[V]
-		Result() V
-		Send(V) (yield V, ok bool)
+		Result() R
+	}
+	// ClosableIterator is an Iterator which can be stopped early,
+	// MoveNext returns false after closed
+	ClosableIterator[V any] interface {
+		Iterator[V]
+		io.Closer
+	}
+	// Generator yields Y, receives S by Send, and returns R
+	Generator[Y, S, R any] interface {
+		ResultIterator[Y, R]
+		Send(S) (yield Y, ok bool)
+		io.Closer
 	}
 )
 
@@ -44,51 +80,151 @@ type (
 
 func zero[V any]() (z V) { return }
 
-func mkNextRecv[V any](f lazyRecv[V], c *co[V], k cont[V]) next[V] {
-	return func(recv V) *step[V] {
-		// c.step = nil
-		f(recv)(c, k) // compute next, set the step if bind called,
-		s := c.step   // otherwise nil
-		c.step = nil
-		return s
+func typeOf[V any]() reflect.Type { return reflect.TypeOf((*V)(nil)).Elem() }
+
+// resume runs the code after yield until the next yield or finished,
+// the step is set if bind called, otherwise zero,
+// recv is nil if resumed by MoveNext
+func (c *co[V]) resume(recv any) {
+	s := c.step
+	c.step = step[V]{}
+	if s.recv != nil {
+		s.recv(recv)(c, s.k)
+	} else {
+		s.f()(c, s.k)
+	}
+}
+
+func (c *co[V]) finished() bool {
+	return c.step.k == nil
+}
+
+// Send resumes the generator with v,
+// which is returned by the suspended yield expression,
+// it panics if it is not the generator receiving V
+func Send[V any](it Iterator[V], v V) (yield V, ok bool) {
+	g, ok := it.(interface{ Send(V) (V, bool) })
+	if !ok {
+		panic(fmt.Sprintf("seq: Send %s to %T, which is not a generator receiving it", typeOf[V](), it))
+	}
+	return g.Send(v)
+}
+
+// Received returns the value sent by Send as S, the zero value if resumed by MoveNext,
+// it panics if the sent value is not S, e.g., sent through YieldFrom to the generator receiving the other type
+func Received[S any](recv any) S {
+	if recv == nil {
+		return zero[S]()
+	}
+	sent, ok := recv.(S)
+	if !ok {
+		panic(fmt.Sprintf("seq: sent value of type %T to the generator receiving %s", recv, typeOf[S]()))
 	}
+	return sent
 }
 
-func mkNext[V any](f lazy[V], c *co[V], k cont[V]) next[V] {
-	return mkNextRecv[V](func(_ V) Seq[V] { return f() }, c, k)
+// Close stops the Iterator early if it implements io.Closer,
+// e.g., the suspended generator is unwound and the pending deferred calls are called
+func Close[V any](it Iterator[V]) error {
+	if c, ok := it.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
 }
 
 // Start / Run a coroutine (Delimited Continuation) in boundary
 func Start[V any](seq Seq[V]) Iterator[V] {
-	var it *generator[V]
-	it = newGenerator[V](mkNext(
-		func() Seq[V] { return seq },
-		&co[V]{},
-		func(t contType, v V) { it.result = v },
-	))
-	return it
+	return StartGenerator[V, V, V](seq)
+}
+
+// StartResult starts a coroutine with the result of type R,
+// which is returned by ReturnValue
+func StartResult[V, R any](seq Seq[V]) ResultIterator[V, R] {
+	return StartGenerator[V, V, R](seq)
+}
+
+// StartGenerator starts a coroutine yielding Y, receiving S and returning R
+func StartGenerator[Y, S, R any](seq Seq[Y]) Generator[Y, S, R] {
+	c := &co[Y]{}
+	c.step = step[Y]{
+		f: func() Seq[Y] { return seq },
+		k: func(t contType, v Y) {
+			c.runDefers() // finished normally or returned
+		},
+	}
+	return &generator[Y, S, R]{co: c}
+}
+
+func (c *co[V]) runDefers() {
+	for len(c.defers) > 0 {
+		n := len(c.defers) - 1
+		f := c.defers[n]
+		c.defers = c.defers[:n]
+		f()
+	}
+}
+
+// unwind calls the pending deferred calls by golang defer stmt and re-panic,
+// so, recover() works in deferred calls the same as in native func,
+// nil p for closing, all deferred calls are called even if some of them panic,
+// returns the panic not recovered by the deferred calls
+func (c *co[V]) unwind(p any) (err *PanicError) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = newPanicError(p)
+		}
+	}()
+	defers := c.defers
+	c.defers = nil
+	for _, f := range defers {
+		//goland:noinspection GoDeferInLoop
+		defer f()
+	}
+	if p != nil {
+		panic(p)
+	}
+	return nil
 }
 
 // Bind collect pending stack frame,
-// When Bind() called, saving k to co.step.next and return immediately
-// When generator.MoveNext() called, co.step.next will be invoked
+// When Bind() called, saving f and k to co.step and return immediately
+// When generator.MoveNext() called, f will be resumed with k
 // supporting yield statement without return value ( <- iter.Send())
 func Bind[V any](v V, f lazy[V]) Seq[V] {
 	return func(c *co[V], k cont[V]) {
-		c.step = &step[V]{
-			value: v,
-			next:  mkNext(f, c, k),
+		c.step = step[V]{value: v, f: f, k: k}
+	}
+}
+
+// BindFrom delegates to the iterator, like `yield from` in python,
+// the innermost delegated generator is driven by the outermost directly instead of re-yielding,
+// so the nested delegation costs O(1) per element, and the sent value reaches the innermost,
+// the error of it is returned by ReturnError after the delegation
+func BindFrom[V any](it Iterator[V], f lazy[V]) Seq[V] {
+	return func(c *co[V], k cont[V]) {
+		c.step = step[V]{
+			from: it,
+			f: func() Seq[V] {
+				if err := it.Err(); err != nil {
+					return ReturnError[V](err)
+				}
+				return f()
+			},
+			k: k,
 		}
 	}
 }
 
 // BindRecv with return value
 // supporting yield expression with return value
-func BindRecv[V any](v V, f lazyRecv[V]) Seq[V] {
+func BindRecv[V, S any](v V, f lazyRecv[V, S]) Seq[V] {
 	return func(c *co[V], k cont[V]) {
-		c.step = &step[V]{
+		c.step = step[V]{
 			value: v,
-			next:  mkNextRecv(f, c, k),
+			recv: func(recv any) Seq[V] {
+				return f(Received[S](recv))
+			},
+			k: k,
 		}
 	}
 }
@@ -97,28 +233,82 @@ func For[V any](
 	cond func() bool,
 	post func(),
 	body Seq[V],
+) Seq[V] {
+	return LabeledFor("", cond, post, body)
+}
+
+// LabeledFor is For with a label,
+// which handles unlabeled break/continue and the break/continue targeting the label,
+// others are propagated to the outer
+//
+// the loop is trampolined, the next iteration runs after the body returned
+// if the body completed without yield, so the stack does not grow with iterations,
+// otherwise, the body is resumed by MoveNext, which restarts the trampoline
+func LabeledFor[V any](
+	label string,
+	cond func() bool,
+	post func(),
+	body Seq[V],
 ) Seq[V] {
 	return func(c *co[V], k cont[V]) {
-		var loop func(skipPost bool)
-		loop = func(skipPost bool) {
-			if post != nil && !skipPost {
-				post()
+		matched := func() bool {
+			if c.label == "" || c.label == label {
+				c.label = ""
+				return true
 			}
-			if cond == nil || cond() {
-				body(c, func(t contType, v V) {
-					switch t {
-					case kNormal, kContinue:
-						loop(false)
-					case kBreak:
-						k(kNormal, zero[V]())
-					case kReturn:
-						k(kReturn, v)
-					default:
-						panic("unreachable")
-					}
-				})
+			return false
+		}
+
+		var (
+			running bool // the body is running on the stack of the trampoline
+			again   bool // the body completed without yield
+			loop    func(skipPost bool)
+		)
+		next := func() {
+			if running {
+				again = true // bounce to the trampoline
 			} else {
-				k(kNormal, zero[V]())
+				loop(false) // resumed after yield
+			}
+		}
+		kk := func(t contType, v V) {
+			switch t {
+			case kNormal:
+				next()
+			case kContinue:
+				if matched() {
+					next()
+				} else {
+					k(kContinue, v)
+				}
+			case kBreak:
+				if matched() {
+					k(kNormal, zero[V]())
+				} else {
+					k(kBreak, v)
+				}
+			case kReturn, kGoto:
+				k(t, v)
+			default:
+				panic("unreachable")
+			}
+		}
+		loop = func(skipPost bool) {
+			for {
+				if post != nil && !skipPost {
+					post()
+				}
+				skipPost = false
+				if cond != nil && !cond() {
+					k(kNormal, zero[V]())
+					return
+				}
+				running, again = true, false
+				body(c, kk)
+				running = false
+				if !again {
+					return
+				}
 			}
 		}
 		loop(true)
@@ -133,99 +323,386 @@ func Loop[V any](body Seq[V]) Seq[V] {
 	return For(nil, nil, body)
 }
 
+// Labeled supporting labeled non-loop stmt, e.g., switch, select, or block as the goto target,
+// labeled break targeting the label completes the stmt normally, which is valid for switch and select only
+func Labeled[V any](label string, s Seq[V]) Seq[V] {
+	return func(c *co[V], k cont[V]) {
+		s(c, func(t contType, v V) {
+			if t == kBreak && c.label == label {
+				c.label = ""
+				k(kNormal, zero[V]())
+			} else {
+				k(t, v)
+			}
+		})
+	}
+}
+
+// Select supporting select stmt, which is breakable,
+// unlabeled break completes the stmt normally
+func Select[V any](s Seq[V]) Seq[V] {
+	return Labeled("", s)
+}
+
+// Switch supporting switch stmt, which is breakable, the same as Select
+func Switch[V any](s Seq[V]) Seq[V] {
+	return Labeled("", s)
+}
+
+// Defer supporting defer stmt, pushing f to the defer stack,
+// f will be called when the generator finished, returned, panicked or closed
+func Defer[V any](f func(), k lazy[V]) Seq[V] {
+	return func(c *co[V], kk cont[V]) {
+		c.defers = append(c.defers, f)
+		k()(c, kk)
+	}
+}
+
+// Dispatch supporting goto stmt, the stmts are split into blocks by labels,
+// blocks are executed in order, starting from the block at *pc,
+// Goto(label, pc, target) jumps to the target block,
+// trampolined the same as LabeledFor
+func Dispatch[V any](label string, pc *int, blocks ...Seq[V]) Seq[V] {
+	return func(c *co[V], k cont[V]) {
+		var (
+			running, again bool
+			loop           func()
+		)
+		next := func() {
+			if running {
+				again = true
+			} else {
+				loop()
+			}
+		}
+		kk := func(t contType, v V) {
+			switch {
+			case t == kNormal:
+				*pc++
+				next()
+			case t == kGoto && c.label == label:
+				c.label = ""
+				next()
+			default:
+				k(t, v)
+			}
+		}
+		loop = func() {
+			for {
+				if *pc >= len(blocks) {
+					k(kNormal, zero[V]())
+					return
+				}
+				running, again = true, false
+				blocks[*pc](c, kk)
+				running = false
+				if !again {
+					return
+				}
+			}
+		}
+		loop()
+	}
+}
+
 func Delay[V any](f lazy[V]) Seq[V] {
 	return func(c *co[V], k cont[V]) {
 		f()(c, k)
 	}
 }
 
+// Combine runs s2 after s1 completed normally,
+// s2 runs after s1 returned if s1 completed without yield, like a tail call,
+// so the stack does not grow with the combined stmts
 func Combine[V any](s1, s2 Seq[V]) Seq[V] {
 	return func(c *co[V], k cont[V]) {
+		running, again := true, false
 		s1(c, func(t contType, v V) {
 			// skip s2 when break/continue/return
 			// notice: break/continue
 			// whether the outer is loop or not
-			if t == kNormal {
-				s2(c, k)
-			} else {
+			if t != kNormal {
 				k(t, v)
+			} else if running {
+				again = true
+			} else {
+				s2(c, k) // resumed after yield
 			}
 		})
+		running = false
+		if again {
+			s2(c, k)
+		}
 	}
 }
 
-func seqOfK[V any](kt contType) Seq[V] {
+// static funcs instead of closures, no allocation
+func normal[V any](_ *co[V], k cont[V])    { k(kNormal, zero[V]()) }
+func break_[V any](_ *co[V], k cont[V])    { k(kBreak, zero[V]()) }
+func continue_[V any](_ *co[V], k cont[V]) { k(kContinue, zero[V]()) }
+func return_[V any](_ *co[V], k cont[V])   { k(kReturn, zero[V]()) }
+
+func Normal[V any]() Seq[V]   { return normal[V] }
+func Break[V any]() Seq[V]    { return break_[V] }
+func Continue[V any]() Seq[V] { return continue_[V] }
+func Return[V any]() Seq[V]   { return return_[V] }
+func BreakLabel[V any](label string) Seq[V] {
+	return func(c *co[V], k cont[V]) {
+		c.label = label
+		k(kBreak, zero[V]())
+	}
+}
+func ContinueLabel[V any](label string) Seq[V] {
+	return func(c *co[V], k cont[V]) {
+		c.label = label
+		k(kContinue, zero[V]())
+	}
+}
+func Goto[V any](label string, pc *int, target int) Seq[V] {
 	return func(c *co[V], k cont[V]) {
-		k(kt, zero[V]())
+		*pc = target
+		c.label = label
+		k(kGoto, zero[V]())
+	}
+}
+func ReturnValue[V, R any](r R) Seq[V] { // supporting generator with return value
+	return func(c *co[V], k cont[V]) {
+		c.result = r
+		k(kReturn, zero[V]())
 	}
 }
 
-func Normal[V any]() Seq[V]   { return seqOfK[V](kNormal) }
-func Break[V any]() Seq[V]    { return seqOfK[V](kBreak) }
-func Continue[V any]() Seq[V] { return seqOfK[V](kContinue) }
-func Return[V any]() Seq[V]   { return seqOfK[V](kReturn) }
-func ReturnValue[V any](v V) Seq[V] { // supporting generator with return value
+// ReturnError finishes the generator with err, which is returned by Err,
+// supporting the failing generator
+func ReturnError[V any](err error) Seq[V] {
 	return func(c *co[V], k cont[V]) {
-		k(kReturn, v)
+		c.err = err
+		k(kReturn, zero[V]())
 	}
 }
 
 // ━━━━━━━━━━━━━━━━━━━━━━━━━━━━ 🅶🅴🅽🅴🆁🅰🆃🅾🆁 ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━
 
 // asyncIter
-type generator[V any] struct {
+type generator[Y, S, R any] struct {
+	co      *co[Y] // the pending step is the continuation after from finished if delegating
 	started bool
-	next    next[V]
-	current/*, ok*/ V
-	result/*, ok*/ V
+	current/*, ok*/ Y
+	err    error          // *PanicError if finished by panicking
+	caught bool           // finish instead of panicking
+	from   Iterator[Y]    // delegated by BindFrom
+	path   []delegatee[Y] // the chain of delegated generators, from.from..., the innermost last
+}
+
+// delegatee is the generator delegated by BindFrom,
+// the innermost of the delegation chain is driven directly, instead of re-yielding level by level
+type delegatee[V any] interface {
+	Iterator[V]
+	delegated() Iterator[V]
+	undelegate(it Iterator[V])
+	drive(sent any) bool
+	abort(p any) *PanicError
+}
+
+// Result returns the value returned by ReturnValue, the zero value if not returned
+func (d *generator[Y, S, R]) Result() R {
+	if d.co.result == nil {
+		return zero[R]()
+	}
+	r, ok := d.co.result.(R)
+	if !ok {
+		panic(fmt.Sprintf("seq: result of type %T returned by the generator of result %s", d.co.result, typeOf[R]()))
+	}
+	return r
 }
 
-func newGenerator[V any](next next[V]) *generator[V] {
-	return &generator[V]{next: next}
+// Err returns the *PanicError if the generator finished by panicking,
+// or the error returned by ReturnError
+func (d *generator[Y, S, R]) Err() error {
+	if d.err != nil {
+		return d.err
+	}
+	return d.co.err
 }
 
-func (d *generator[V]) Result() V {
-	return d.result
+func (d *generator[Y, S, R]) catch() {
+	d.caught = true
+}
+
+// panic if not caught
+func (d *generator[Y, S, R]) fail(err *PanicError) {
+	if !d.failed(err) {
+		panic(err)
+	}
 }
 
-func (d *generator[V]) Current() V {
+// records the panic, returns true if caught
+func (d *generator[Y, S, R]) failed(err *PanicError) bool {
+	d.err = err
+	return d.caught
+}
+
+func (d *generator[Y, S, R]) Current() Y {
 	// assert(d.started)
 	return d.current
 }
 
-func (d *generator[V]) MoveNext() bool {
+func (d *generator[Y, S, R]) MoveNext() bool {
 	d.started = true
-	return d.moveNext(zero[V]())
+	return d.moveNext(nil)
 }
 
-func (d *generator[V]) Send(v V) (V, bool) {
+func (d *generator[Y, S, R]) Send(v S) (Y, bool) {
 	if !d.started {
 		// if the generator is not at a yield expression when this method is called,
 		// it will first be let to advance to the first yield expression before sending the value.
 		// so, the first current value would be skipped also
 		if !d.MoveNext() {
-			return zero[V](), false
+			return zero[Y](), false
 		}
 	}
 	if d.moveNext(v) {
 		return d.current, true
 	} else {
-		return zero[V](), false
+		return zero[Y](), false
 	}
 }
 
-func (d *generator[V]) moveNext(sent V) bool {
-	if d.next == nil {
-		return false
+// Close stops the suspended generator, and calls the pending deferred calls,
+// the delegated iterator is closed first,
+// MoveNext returns false after closed
+func (d *generator[Y, S, R]) Close() error {
+	if d.co.finished() {
+		return nil // finished or closed
 	}
-	s := d.next(sent) // compute next step
-	if s == nil {
-		d.next = nil
-		d.current = zero[V]()
-		return false
-	} else {
-		d.next = s.next
-		d.current = s.value
-		return true
+	from := d.from
+	d.from, d.path = nil, nil
+	d.current = zero[Y]()
+	d.co.step = step[Y]{}
+
+	var first error
+	if from != nil {
+		first = Close(from)
+	}
+	if err := d.co.unwind(nil); err != nil {
+		d.fail(err)
+		return err
+	}
+	return first
+}
+
+func (d *generator[Y, S, R]) delegated() Iterator[Y] {
+	return d.from
+}
+
+// the delegated it has finished
+func (d *generator[Y, S, R]) undelegate(it Iterator[Y]) {
+	if d.from == it {
+		d.from = nil
+	}
+}
+
+func (d *generator[Y, S, R]) drive(sent any) bool {
+	d.started = true
+	return d.moveNext(sent)
+}
+
+// abort finishes the generator by the panic raised from the delegated,
+// returns nil if recovered by the deferred calls or caught
+func (d *generator[Y, S, R]) abort(p any) *PanicError {
+	d.from, d.path = nil, nil
+	d.current = zero[Y]()
+	d.co.step = step[Y]{}
+	if err := d.co.unwind(p); err != nil && !d.failed(err) {
+		return err
+	}
+	return nil
+}
+
+func (d *generator[Y, S, R]) moveNext(sent any) (ok bool) {
+	defer func() {
+		if p := recover(); p != nil {
+			ok = d.raise(p)
+		}
+	}()
+
+	for {
+		// drive the innermost delegated generator directly
+		if n := len(d.path); n > 0 {
+			top := d.path[n-1]
+			var parent delegatee[Y] = d
+			if n > 1 {
+				parent = d.path[n-2]
+			}
+			if parent.delegated() != top {
+				// stale, the delegating has been driven by others
+				d.path = d.path[:n-1]
+				continue
+			}
+			if g, ok := top.delegated().(delegatee[Y]); ok {
+				d.path = append(d.path, g)
+				continue
+			}
+			if top.drive(sent) {
+				d.current = top.Current()
+				return true
+			}
+			// finished, the delegating resumes
+			sent = nil
+			d.path = d.path[:n-1]
+			parent.undelegate(top)
+			continue
+		}
+
+		if d.from != nil {
+			if g, ok := d.from.(delegatee[Y]); ok {
+				d.path = append(d.path, g)
+				continue
+			}
+			if d.from.MoveNext() {
+				d.current = d.from.Current()
+				return true
+			}
+			d.from = nil
+		}
+
+		if d.co.finished() {
+			d.current = zero[Y]()
+			return false
+		}
+		d.co.resume(sent) // compute next step
+		sent = nil
+		switch s := &d.co.step; {
+		case s.k == nil:
+			d.current = zero[Y]()
+			return false
+		case s.from != nil:
+			d.from, s.from = s.from, nil
+		default:
+			d.current = s.value
+			return true
+		}
+	}
+}
+
+// raise the panic to the delegating generators from the inside out,
+// the panicked innermost has finished by itself
+func (d *generator[Y, S, R]) raise(p any) bool {
+	for n := len(d.path); n > 1; n-- {
+		d.path = d.path[:n-1]
+		err := d.path[n-2].abort(p)
+		if err == nil {
+			// recovered or caught, the delegating of it resumes
+			return d.moveNext(nil)
+		}
+		p = err
+	}
+	d.path = nil
+
+	// finished, the generator can't be resumed after panicking
+	// return false if recovered by deferred calls
+	if err := d.abort(p); err != nil {
+		panic(err)
 	}
+	return false
 }
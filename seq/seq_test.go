@@ -1,7 +1,11 @@
 package seq
 
 import (
+	"errors"
 	"reflect"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -61,6 +65,73 @@ func TestSeq(t *testing.T) {
 			},
 			expect: []int{1, 3, 5, 7, 9},
 		},
+		{
+			name: "YieldLabeled",
+			factory: func() Iterator[int] {
+				//	outer:
+				//	for i := 0; i < 3; i++ {
+				//		for j := 0; ; j++ {
+				//			if j == 2 {
+				//				continue outer
+				//			}
+				//			if i == 2 {
+				//				break outer
+				//			}
+				//			yield i*10 + j
+				//		}
+				//	}
+				return Start(Delay(func() Seq[int] {
+					i := 0
+					return LabeledFor("outer",
+						func() bool { return i < 3 },
+						func() { i++ },
+						Delay(func() Seq[int] {
+							j := 0
+							return For(
+								nil,
+								func() { j++ },
+								Delay(func() Seq[int] {
+									if j == 2 {
+										return ContinueLabel[int]("outer")
+									}
+									if i == 2 {
+										return BreakLabel[int]("outer")
+									}
+									return Bind(i*10+j, Normal[int])
+								}),
+							)
+						}),
+					)
+				}))
+			},
+			expect: []int{0, 1, 10, 11},
+		},
+		{
+			name: "YieldGoto",
+			factory: func() Iterator[int] {
+				//	i := 0
+				//	loop:
+				//	yield i
+				//	i++
+				//	if i < 3 {
+				//		goto loop
+				//	}
+				return Start(Delay(func() Seq[int] {
+					i := 0
+					pc := 0
+					return Dispatch("loop", &pc, Delay(func() Seq[int] {
+						return Bind(i, func() Seq[int] {
+							i++
+							if i < 3 {
+								return Goto[int]("loop", &pc, 0)
+							}
+							return Normal[int]()
+						})
+					}))
+				}))
+			},
+			expect: []int{0, 1, 2},
+		},
 	}
 	for _, it := range all {
 		t.Run(it.name, func(t *testing.T) {
@@ -101,7 +172,7 @@ func TestSend(t *testing.T) {
 	}
 
 	iter := seq()
-	g := iter.(*generator[int])
+	g := iter.(*generator[int, int, int])
 	// g.MoveNext()
 
 	var yieldXS []int
@@ -120,6 +191,42 @@ func TestSend(t *testing.T) {
 	assertEqual(t, yieldXS, []int{1, 2})
 }
 
+func TestGenerator(t *testing.T) {
+	// sum := 0
+	// for {
+	// 		n := yield strconv.Itoa(sum)
+	// 		if n < 0 { return sum }
+	// 		sum += n
+	// }
+	seq := func() Generator[string, int, bool] {
+		return StartGenerator[string, int, bool](Delay(func() Seq[string] {
+			sum := 0
+			return Loop(Delay(func() Seq[string] {
+				return BindRecv(strconv.Itoa(sum), func(n int) Seq[string] {
+					if n < 0 {
+						return ReturnValue[string](sum > 0)
+					}
+					sum += n
+					return Normal[string]()
+				})
+			}))
+		}))
+	}
+
+	g := seq()
+	var got []string
+	for _, n := range []int{1, 2, 3} {
+		s, ok := g.Send(n)
+		assertEqual(t, ok, true)
+		got = append(got, s)
+	}
+	_, ok := g.Send(-1)
+	assertEqual(t, ok, false)
+	// the first sent value is skipped
+	assertEqual(t, got, []string{"1", "3", "6"})
+	assertEqual(t, g.Result(), true)
+}
+
 func TestResult(t *testing.T) {
 	// yield 1
 	// return 42
@@ -136,10 +243,499 @@ func TestResult(t *testing.T) {
 	got := iter2slice(iter)
 	assertEqual(t, got, []int{1})
 
-	g := iter.(*generator[int])
+	g := iter.(*generator[int, int, int])
 	assertEqual(t, g.Result(), 42)
 }
 
+func TestStartResult(t *testing.T) {
+	// yield 1
+	// return "done"
+	seq := func() ResultIterator[int, string] {
+		return StartResult[int, string](Delay(func() Seq[int] {
+			return Bind(1, func() Seq[int] {
+				return ReturnValue[int]("done")
+			})
+		}))
+	}
+
+	iter := seq()
+	assertEqual(t, iter.Result(), "")
+
+	got := iter2slice[int](iter)
+	assertEqual(t, got, []int{1})
+	assertEqual(t, iter.Result(), "done")
+}
+
+func TestDefer(t *testing.T) {
+	var log []string
+	// defer log("a")
+	// yield 1
+	// defer log("b")
+	// yield 2
+	seq := func() Iterator[int] {
+		return Start(Delay(func() Seq[int] {
+			return Defer(func() { log = append(log, "a") }, func() Seq[int] {
+				return Bind(1, func() Seq[int] {
+					return Defer(func() { log = append(log, "b") }, func() Seq[int] {
+						return Bind(2, Normal[int])
+					})
+				})
+			})
+		}))
+	}
+
+	{
+		log = nil
+		got := iter2slice(seq())
+		assertEqual(t, got, []int{1, 2})
+		assertEqual(t, log, []string{"b", "a"})
+	}
+
+	{
+		log = nil
+		g := seq().(*generator[int, int, int])
+		assertEqual(t, g.MoveNext(), true)
+		assertEqual(t, log, []string(nil))
+		_ = g.Close()
+		assertEqual(t, log, []string{"a"})
+		assertEqual(t, g.MoveNext(), false)
+		_ = g.Close()
+		assertEqual(t, log, []string{"a"})
+	}
+}
+
+func TestClose(t *testing.T) {
+	var log []string
+	// defer log("a")
+	// defer panic("b")
+	// for { yield 1 }
+	seq := func() Iterator[int] {
+		return Start(Delay(func() Seq[int] {
+			return Defer(func() { log = append(log, "a") }, func() Seq[int] {
+				return Defer(func() { panic("b") }, func() Seq[int] {
+					return Loop(Delay(func() Seq[int] {
+						return Bind(1, Normal[int])
+					}))
+				})
+			})
+		}))
+	}
+
+	it := seq()
+	_, ok := it.(ClosableIterator[int])
+	assertEqual(t, ok, true)
+	assertEqual(t, it.MoveNext(), true)
+
+	func() {
+		defer func() {
+			assertEqual(t, recover().(*PanicError).Value, "b")
+		}()
+		_ = Close(it)
+	}()
+	// all deferred calls are called even if some of them panic
+	assertEqual(t, log, []string{"a"})
+	assertEqual(t, it.MoveNext(), false)
+	assertEqual(t, Close(it), nil)
+
+	// do nothing if not closable
+	assertEqual(t, Close(NewSliceIter([]int{1})), nil)
+}
+
+func TestPanic(t *testing.T) {
+	// yield 1
+	// panic("boom")
+	seq := func() Iterator[int] {
+		return Start(Delay(func() Seq[int] {
+			return Bind(1, func() Seq[int] {
+				panic("boom")
+			})
+		}))
+	}
+
+	it := seq()
+	assertEqual(t, it.MoveNext(), true)
+	func() {
+		defer func() {
+			err := recover().(*PanicError)
+			assertEqual(t, err.Value, "boom")
+			assertEqual(t, strings.Contains(err.Pos, "seq_test.go:"), true)
+			assertEqual(t, it.Err(), error(err))
+		}()
+		it.MoveNext()
+	}()
+	// finished
+	assertEqual(t, it.MoveNext(), false)
+
+	// errors instead of panics
+	it = Catch(seq())
+	got := iter2slice(it)
+	assertEqual(t, got, []int{1})
+	err, ok := it.Err().(*PanicError)
+	assertEqual(t, ok, true)
+	assertEqual(t, err.Value, "boom")
+
+	// panic in the nested generator keeps the original position
+	outer := Catch(Start(Delay(func() Seq[int] {
+		inner := seq()
+		return While(inner.MoveNext, Delay(func() Seq[int] {
+			return Bind(inner.Current(), Normal[int])
+		}))
+	})))
+	got = iter2slice(outer)
+	assertEqual(t, got, []int{1})
+	assertEqual(t, outer.Err().(*PanicError).Pos, err.Pos)
+}
+
+func TestReturnError(t *testing.T) {
+	errBoom := errors.New("boom")
+	// yield 1
+	// if fail { return errBoom }
+	// yield 2
+	seq := func(fail bool) Iterator[int] {
+		return Start(Delay(func() Seq[int] {
+			return Bind(1, func() Seq[int] {
+				if fail {
+					return ReturnError[int](errBoom)
+				}
+				return Bind(2, Normal[int])
+			})
+		}))
+	}
+
+	it := seq(true)
+	assertEqual(t, iter2slice(it), []int{1})
+	assertEqual(t, it.Err(), errBoom)
+
+	it = seq(false)
+	assertEqual(t, iter2slice(it), []int{1, 2})
+	assertEqual(t, it.Err(), nil)
+
+	// the iterators which never fail
+	assertEqual(t, NewSliceIter([]int{1}).Err(), nil)
+}
+
+func TestDeferRecover(t *testing.T) {
+	var recovered any
+	// defer func() { recovered = recover() }()
+	// yield 1
+	// panic("boom")
+	seq := func() Iterator[int] {
+		return Start(Delay(func() Seq[int] {
+			return Defer(func() { recovered = recover() }, func() Seq[int] {
+				return Bind(1, func() Seq[int] {
+					panic("boom")
+				})
+			})
+		}))
+	}
+
+	got := iter2slice(seq())
+	assertEqual(t, got, []int{1})
+	assertEqual(t, recovered, "boom")
+}
+
+func TestStackSafe(t *testing.T) {
+	// the non-yielding iterations run in constant stack space
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+	const n = 1_000_000
+
+	// for i := 0; i < n; i++ {
+	//		if i < n-1 { continue }
+	//		yield i
+	// }
+	loop := Start(Delay(func() Seq[int] {
+		i := 0
+		return For(func() bool { return i < n }, func() { i++ }, Delay(func() Seq[int] {
+			if i < n-1 {
+				return Continue[int]()
+			}
+			return Bind(i, Normal[int])
+		}))
+	}))
+	assertEqual(t, iter2slice(loop), []int{n - 1})
+
+	// i := 0
+	// loop:
+	// i++
+	// if i < n { goto loop }
+	// yield i
+	goto_ := Start(Delay(func() Seq[int] {
+		i := 0
+		pc := 0
+		return Dispatch("loop", &pc, Delay(func() Seq[int] {
+			i++
+			if i < n {
+				return Goto[int]("loop", &pc, 0)
+			}
+			return Bind(i, Normal[int])
+		}))
+	}))
+	assertEqual(t, iter2slice(goto_), []int{n})
+}
+
+func TestBindFrom(t *testing.T) {
+	// yield 0
+	// yield from NewSliceIter(xs)
+	// yield -1
+	outer := func(it Iterator[int]) Iterator[int] {
+		return Start(Delay(func() Seq[int] {
+			return Bind(0, func() Seq[int] {
+				return BindFrom(it, func() Seq[int] {
+					return Bind(-1, Normal[int])
+				})
+			})
+		}))
+	}
+	assertEqual(t, iter2slice(outer(&values[int]{xs: []int{1, 2}})), []int{0, 1, 2, -1})
+	assertEqual(t, iter2slice(outer(outer(&values[int]{xs: []int{1}}))), []int{0, 0, 1, -1, -1})
+
+	// the finished generator yields nothing
+	done := outer(&values[int]{})
+	iter2slice(done)
+	assertEqual(t, iter2slice(outer(done)), []int{0, -1})
+
+	// the error of the delegated is returned
+	errBoom := errors.New("boom")
+	it := outer(Start(Delay(func() Seq[int] {
+		return Bind(1, func() Seq[int] {
+			return ReturnError[int](errBoom)
+		})
+	})))
+	assertEqual(t, iter2slice(it), []int{0, 1})
+	assertEqual(t, it.Err(), errBoom)
+
+	// the panic of the caught delegated is returned as error
+	it = outer(Catch(Start(Delay(func() Seq[int] {
+		return Bind(1, func() Seq[int] {
+			panic("boom")
+		})
+	}))))
+	assertEqual(t, iter2slice(it), []int{0, 1})
+	assertEqual(t, it.Err().(*PanicError).Value, "boom")
+
+	// the panic of the delegated is propagated,
+	// the deferred calls are called from the innermost
+	var log []string
+	it = Catch(Start(Delay(func() Seq[int] {
+		return Defer(func() { log = append(log, "outer") }, func() Seq[int] {
+			return BindFrom(Start(Delay(func() Seq[int] {
+				return Defer(func() { log = append(log, "inner") }, func() Seq[int] {
+					return Bind(1, func() Seq[int] {
+						panic("boom")
+					})
+				})
+			})), Normal[int])
+		})
+	})))
+	assertEqual(t, iter2slice(it), []int{1})
+	assertEqual(t, it.Err().(*PanicError).Value, "boom")
+	assertEqual(t, log, []string{"inner", "outer"})
+}
+
+func TestBindFromSend(t *testing.T) {
+	// sent values reach the delegated generator
+	// inner: for { n := yield sum; sum += n }
+	// outer: yield from inner
+	var sum int
+	inner := StartGenerator[int, int, any](Delay(func() Seq[int] {
+		return Loop(Delay(func() Seq[int] {
+			return BindRecv(sum, func(n int) Seq[int] {
+				sum += n
+				return Normal[int]()
+			})
+		}))
+	}))
+	outer := StartGenerator[int, int, any](Delay(func() Seq[int] {
+		return BindFrom[int](inner, Normal[int])
+	}))
+
+	var got []int
+	for _, n := range []int{1, 2, 3} {
+		v, ok := outer.Send(n)
+		assertEqual(t, ok, true)
+		got = append(got, v)
+	}
+	// the first sent value is skipped
+	assertEqual(t, got, []int{1, 3, 6})
+	assertEqual(t, sum, 6)
+
+	// closing the delegating closes the delegated
+	var log []string
+	it := Start(Delay(func() Seq[int] {
+		return Defer(func() { log = append(log, "outer") }, func() Seq[int] {
+			return BindFrom(Start(Delay(func() Seq[int] {
+				return Defer(func() { log = append(log, "inner") }, func() Seq[int] {
+					return Loop(Delay(func() Seq[int] {
+						return Bind(1, Normal[int])
+					}))
+				})
+			})), Normal[int])
+		})
+	}))
+	assertEqual(t, it.MoveNext(), true)
+	assertEqual(t, Close(it), nil)
+	assertEqual(t, log, []string{"inner", "outer"})
+	assertEqual(t, it.MoveNext(), false)
+}
+
+func TestSendMismatch(t *testing.T) {
+	// the sent value is not dropped silently if the delegated receives the other type
+	inner := StartGenerator[int, string, any](Delay(func() Seq[int] {
+		return BindRecv(1, func(s string) Seq[int] {
+			return Normal[int]()
+		})
+	}))
+	outer := StartGenerator[int, int, any](Delay(func() Seq[int] {
+		return BindFrom[int](inner, Normal[int])
+	}))
+	assertEqual(t, outer.MoveNext(), true)
+	func() {
+		defer func() {
+			err := recover().(*PanicError)
+			assertEqual(t, err.Value, "seq: sent value of type int to the generator receiving string")
+		}()
+		outer.Send(42)
+		t.Errorf("expect panic")
+	}()
+
+	// not a generator
+	func() {
+		defer func() {
+			assertEqual(t, recover(), "seq: Send int to *seq.values[int], which is not a generator receiving it")
+		}()
+		Send[int](&values[int]{xs: []int{1}}, 42)
+		t.Errorf("expect panic")
+	}()
+}
+
+func TestBindFromNested(t *testing.T) {
+	// the nested delegation runs in constant stack space
+	defer debug.SetMaxStack(debug.SetMaxStack(1 << 20))
+	const n = 100_000
+
+	// func count(i int) Iter[int] {
+	//		if i == n { return }
+	//		yield i
+	//		yield from count(i+1)
+	// }
+	var count func(i int) Iterator[int]
+	count = func(i int) Iterator[int] {
+		return Start(Delay(func() Seq[int] {
+			if i == n {
+				return Normal[int]()
+			}
+			return Bind(i, func() Seq[int] {
+				return BindFrom(count(i+1), Normal[int])
+			})
+		}))
+	}
+	xs := iter2slice(count(0))
+	assertEqual(t, len(xs), n)
+	assertEqual(t, xs[n-1], n-1)
+}
+
+func TestStateMachine(t *testing.T) {
+	// defer log("defer")
+	// for i := 0; i < n; i++ {
+	//		sum += yield i
+	// }
+	// yield from xs
+	// return sum
+	var log []string
+	gen := func(n int, xs Iterator[int]) ResultIterator[int, int] {
+		return StartResult[int, int](Delay(func() Seq[int] {
+			var i, sum int
+			return StateMachine(func(m *Machine[int]) {
+				switch m.State {
+				case 1:
+					goto resume
+				case 2:
+					goto from
+				}
+				m.Defer(func() { log = append(log, "defer") })
+				i = 0
+			loop:
+				if !(i < n) {
+					goto done
+				}
+				m.Yield(i, 1)
+				return
+			resume:
+				if v, ok := m.Recv.(int); ok {
+					sum += v
+				}
+				i++
+				goto loop
+			done:
+				m.YieldFrom(xs, 2)
+				return
+			from:
+				m.ReturnValue(sum)
+			})
+		}))
+	}
+
+	it := gen(3, &values[int]{xs: []int{-1}})
+	assertEqual(t, iter2slice[int](it), []int{0, 1, 2, -1})
+	assertEqual(t, it.Result(), 0)
+	assertEqual(t, log, []string{"defer"})
+
+	// the sent values are received by Recv, the last one is sent to the finished
+	it = gen(3, &values[int]{})
+	for _, v := range []int{1, 2, 3, 4} {
+		Send[int](it, v)
+	}
+	assertEqual(t, it.Result(), 1+2+3)
+
+	// the error of the delegated is returned
+	errBoom := errors.New("boom")
+	it = gen(1, Start(ReturnError[int](errBoom)))
+	assertEqual(t, iter2slice[int](it), []int{0})
+	assertEqual(t, it.Err(), errBoom)
+	assertEqual(t, it.Result(), 0)
+
+	// the closed machine runs the deferred calls
+	log = nil
+	it = gen(3, &values[int]{})
+	assertEqual(t, it.MoveNext(), true)
+	assertEqual(t, Close[int](it), nil)
+	assertEqual(t, log, []string{"defer"})
+	assertEqual(t, it.MoveNext(), false)
+}
+
+func TestStateMachineReturnError(t *testing.T) {
+	errBoom := errors.New("boom")
+	it := Start(StateMachine(func(m *Machine[int]) {
+		switch m.State {
+		case 1:
+			goto resume
+		}
+		m.Yield(1, 1)
+		return
+	resume:
+		m.ReturnError(errBoom)
+	}))
+	assertEqual(t, iter2slice(it), []int{1})
+	assertEqual(t, it.Err(), errBoom)
+}
+
+// values is the iterator which is not a generator
+type values[V any] struct {
+	noErr
+	xs  []V
+	cur V
+}
+
+func (s *values[V]) MoveNext() bool {
+	if len(s.xs) == 0 {
+		return false
+	}
+	s.cur, s.xs = s.xs[0], s.xs[1:]
+	return true
+}
+
+func (s *values[V]) Current() V { return s.cur }
+
 func iter2slice[V any](it Iterator[V]) (xs []V) {
 	for it.MoveNext() {
 		xs = append(xs, it.Current())